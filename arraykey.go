@@ -0,0 +1,168 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"fmt"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"strconv"
+)
+
+// diffKeyedSlice 使用元素的 key 字段对齐 source 和 patch 两个数组并输出差异：
+// 先删除 patch 中不存在的元素，再从后向前移动顺序变化的元素、添加新的元素，
+// 最后递归比较 key 相同的元素。不满足按 key 比较的条件时不输出任何差异并返回 false
+func diffKeyedSlice(diffs *diffs, path decode.Pointer, source, patch *decode.JsonNode, key string, cfg *diffConfig) bool {
+	srcKeys, ok := identities(source, key)
+	if !ok {
+		return false
+	}
+	tarKeys, ok := identities(patch, key)
+	if !ok {
+		return false
+	}
+	srcIndex := make(map[string]int, len(srcKeys))
+	for i, k := range srcKeys {
+		srcIndex[k] = i
+	}
+	tarIndex := make(map[string]int, len(tarKeys))
+	for i, k := range tarKeys {
+		tarIndex[k] = i
+	}
+
+	// cur 模拟应用差异过程中数组的状态
+	cur := make([]string, 0, len(srcKeys))
+	for i, k := range srcKeys {
+		if _, ok := tarIndex[k]; ok {
+			cur = append(cur, k)
+			continue
+		}
+		currPath := path.Append(strconv.Itoa(len(cur)))
+		diffs.add(newDiffNode(DiffTypeRemove, currPath.String(), source.Children[i], "", cfg.flags))
+	}
+
+	// 两边都存在的元素中，属于最长公共子序列的元素不需要移动
+	common := make([]string, 0, len(tarKeys))
+	for _, k := range tarKeys {
+		if _, ok := srcIndex[k]; ok {
+			common = append(common, k)
+		}
+	}
+	stable := lcsKeys(cur, common)
+
+	// 从后向前处理，每个需要移动或添加的元素都插入到它在 patch 中的后一个元素之前
+	for i := len(tarKeys) - 1; i >= 0; i-- {
+		k := tarKeys[i]
+		if stable[k] {
+			continue
+		}
+		to := len(cur)
+		if i+1 < len(tarKeys) {
+			to = indexOfKey(cur, tarKeys[i+1])
+		}
+		if _, ok := srcIndex[k]; ok {
+			from := indexOfKey(cur, k)
+			cur = append(cur[:from], cur[from+1:]...)
+			if from < to {
+				to--
+			}
+			if from != to {
+				diffs.add(newDiffNode(DiffTypeMove, path.Append(strconv.Itoa(to)).String(), nil,
+					path.Append(strconv.Itoa(from)).String(), cfg.flags))
+			}
+		} else {
+			currPath := path.Append(strconv.Itoa(to))
+			diffs.add(newDiffNode(DiffTypeAdd, currPath.String(), patch.Children[i], "", cfg.flags))
+		}
+		cur = append(cur, "")
+		copy(cur[to+1:], cur[to:])
+		cur[to] = k
+	}
+
+	for i, k := range tarKeys {
+		if j, ok := srcIndex[k]; ok {
+			diff(diffs, path.Append(strconv.Itoa(i)), source.Children[j], patch.Children[i], cfg)
+		}
+	}
+	return true
+}
+
+// identities 返回数组中每个元素的身份标识，元素不是对象、缺少 key 字段、
+// key 字段是对象或数组，或者身份标识重复时第二个参数返回 false
+func identities(node *decode.JsonNode, key string) ([]string, bool) {
+	res := make([]string, len(node.Children))
+	seen := make(map[string]bool, len(node.Children))
+	for i, child := range node.Children {
+		if child == nil || child.Type != decode.JsonNodeTypeObject {
+			return nil, false
+		}
+		v, ok := child.ChildrenMap[key]
+		if !ok || v == nil || v.Type != decode.JsonNodeTypeValue {
+			return nil, false
+		}
+		id := fmt.Sprintf("%T:%v", v.Value, v.Value)
+		if n, ok := decode.ToNumber(v.Value); ok {
+			id = "number:" + n.Canonical()
+		}
+		if seen[id] {
+			return nil, false
+		}
+		seen[id] = true
+		res[i] = id
+	}
+	return res, true
+}
+
+// lcsKeys 返回 first 和 second 的最长公共子序列中的所有元素
+func lcsKeys(first, second []string) map[string]bool {
+	dp := make([][]int, len(first)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(second)+1)
+	}
+	for i := 1; i <= len(first); i++ {
+		for j := 1; j <= len(second); j++ {
+			if first[i-1] == second[j-1] {
+				dp[i][j] = dp[i-1][j-1] + 1
+			} else {
+				dp[i][j] = max(dp[i-1][j], dp[i][j-1])
+			}
+		}
+	}
+	res := make(map[string]bool, dp[len(first)][len(second)])
+	for i, j := len(first), len(second); i > 0 && j > 0; {
+		switch {
+		case first[i-1] == second[j-1]:
+			res[first[i-1]] = true
+			i--
+			j--
+		case dp[i-1][j] >= dp[i][j-1]:
+			i--
+		default:
+			j--
+		}
+	}
+	return res
+}
+
+func indexOfKey(keys []string, key string) int {
+	for i, k := range keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
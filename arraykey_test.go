@@ -0,0 +1,144 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"fmt"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"math/rand"
+	"testing"
+)
+
+func TestUseArrayKeyOption(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		tar     string
+		options []DiffOption
+		want    string
+	}{
+		{
+			"change one field",
+			`{"users": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}, {"id": 3, "name": "c"}]}`,
+			`{"users": [{"id": 1, "name": "a"}, {"id": 2, "name": "B"}, {"id": 3, "name": "c"}]}`,
+			[]DiffOption{UseArrayKeyOption("/users/*", "id")},
+			`[{"op":"replace","path":"/users/1/name","value":"B"}]`,
+		},
+		{
+			"remove and change",
+			`{"users": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}, {"id": 3, "name": "c"}]}`,
+			`{"users": [{"id": 2, "name": "b"}, {"id": 3, "name": "C"}]}`,
+			[]DiffOption{UseArrayKeyOption("/users/*", "id")},
+			`[{"op":"remove","path":"/users/0"},{"op":"replace","path":"/users/1/name","value":"C"}]`,
+		},
+		{
+			"reorder",
+			`[{"id": "a"}, {"id": "b"}, {"id": "c"}, {"id": "d"}]`,
+			`[{"id": "b"}, {"id": "c"}, {"id": "d"}, {"id": "a", "x": 1}]`,
+			[]DiffOption{UseArrayKeyOption("/*", "id")},
+			`[{"op":"move","path":"/3","from":"/0"},{"op":"add","path":"/3/x","value":1}]`,
+		},
+		{
+			"add in the middle",
+			`[{"id": 1}, {"id": 3}]`,
+			`[{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}]`,
+			[]DiffOption{UseArrayKeyOption("/*", "id")},
+			`[{"op":"add","path":"/2","value":{"id":4}},{"op":"add","path":"/1","value":{"id":2}}]`,
+		},
+		{
+			"wildcard in the middle",
+			`{"pods": [{"containers": [{"name": "app", "image": "v1"}, {"name": "sidecar", "image": "s1"}]}]}`,
+			`{"pods": [{"containers": [{"name": "sidecar", "image": "s1"}, {"name": "app", "image": "v2"}]}]}`,
+			[]DiffOption{UseArrayKeyOption("/pods/*/containers/*", "name")},
+			`[{"op":"move","path":"/pods/0/containers/0","from":"/pods/0/containers/1"},` +
+				`{"op":"replace","path":"/pods/0/containers/1/image","value":"v2"}]`,
+		},
+		{
+			"pattern does not match",
+			`{"users": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}]}`,
+			`{"users": [{"id": 2, "name": "b"}, {"id": 1, "name": "a"}]}`,
+			[]DiffOption{UseArrayKeyOption("/members/*", "id")},
+			`[{"op":"remove","path":"/users/0"},{"op":"add","path":"/users/1","value":{"id":1,"name":"a"}}]`,
+		},
+		{
+			"duplicate keys fall back",
+			`[{"id": 1, "v": 1}, {"id": 1, "v": 2}]`,
+			`[{"id": 1, "v": 2}]`,
+			[]DiffOption{UseArrayKeyOption("/*", "id")},
+			`[{"op":"remove","path":"/0"}]`,
+		},
+		{
+			"numeric keys are compared exactly",
+			`[{"id": 1.0, "v": 1}, {"id": 9007199254740993, "v": 2}]`,
+			`[{"id": 9007199254740993, "v": 2}, {"id": 1, "v": 1}]`,
+			[]DiffOption{UseArrayKeyOption("/*", "id")},
+			`[{"op":"move","path":"/0","from":"/1"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := AsDiffs([]byte(tt.src), []byte(tt.tar), tt.options...)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if string(diffs) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, diffs)
+			}
+			res, err := MergeDiff([]byte(tt.src), diffs)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			got, _ := decode.Unmarshal(res)
+			want, _ := decode.Unmarshal([]byte(tt.tar))
+			if !got.Equal(want) {
+				t.Errorf("want %s, got %s", tt.tar, res)
+			}
+		})
+	}
+}
+
+func TestUseArrayKeyOption_roundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	list := func() string {
+		ids := r.Perm(8)[:r.Intn(8)]
+		s := "["
+		for i, id := range ids {
+			if i > 0 {
+				s += ","
+			}
+			s += fmt.Sprintf(`{"id": %d, "v": %d}`, id, r.Intn(2))
+		}
+		return s + "]"
+	}
+	for i := 0; i < 500; i++ {
+		src, tar := list(), list()
+		diffs, err := AsDiffs([]byte(src), []byte(tar), UseArrayKeyOption("/*", "id"))
+		if err != nil {
+			t.Fatalf("got an error: %v", err)
+		}
+		res, err := MergeDiff([]byte(src), diffs)
+		if err != nil {
+			t.Fatalf("%s -> %s: %s: %v", src, tar, diffs, err)
+		}
+		got, _ := decode.Unmarshal(res)
+		want, _ := decode.Unmarshal([]byte(tar))
+		if !got.Equal(want) {
+			t.Fatalf("%s -> %s: %s: got %s", src, tar, diffs, res)
+		}
+	}
+}
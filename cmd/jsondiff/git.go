@@ -0,0 +1,401 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	jsondiff "github.com/520MianXiangDuiXiang520/json-diff"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+)
+
+// devNull 是 git 在新增或删除文件时传给外部差异程序的文件名
+const devNull = "/dev/null"
+
+// runGitDiff 实现 git 的外部差异程序协议（diff.<driver>.command 或 GIT_EXTERNAL_DIFF），参数为
+// path old-file old-hex old-mode new-file new-hex new-mode，重命名时 git 会追加 new-path 和重命名信息两个参数。
+// 差异通过 GetDiffNode 计算，只输出结构上的修改，key 的顺序和格式的变化会被忽略。
+// 文件不是合法的 JSON 时退回到整个文件的替换。git 会在外部差异程序返回非 0 时中止，因此只在出错时返回 2
+func runGitDiff(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("git-diff [-color] [-context n] PATH OLD-FILE OLD-HEX OLD-MODE NEW-FILE NEW-HEX NEW-MODE", stderr)
+	color := fs.Bool("color", false, "colorize the output")
+	context := fs.Int("context", 3, "number of context lines")
+	if !parseFlags(fs, args, -1) {
+		return exitError
+	}
+	if fs.NArg() != 7 && fs.NArg() != 9 {
+		fs.Usage()
+		return exitError
+	}
+	path, newPath := fs.Arg(0), fs.Arg(0)
+	if fs.NArg() == 9 {
+		newPath = fs.Arg(7)
+	}
+	oldFile, newFile := fs.Arg(1), fs.Arg(4)
+	oldData, err := readGitFile(oldFile)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	newData, err := readGitFile(newFile)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	opts := &jsondiff.RenderOptions{
+		Context:  *context,
+		Color:    *color,
+		FromName: gitName("a/", path, oldFile),
+		ToName:   gitName("b/", newPath, newFile),
+	}
+	var out bytes.Buffer
+	oldNode, oldErr := parseGitFile(oldFile, oldData)
+	newNode, newErr := parseGitFile(newFile, newData)
+	switch {
+	case oldErr != nil || newErr != nil:
+		writeTextDiff(&out, opts, oldData, newData)
+	case oldNode == nil || newNode == nil:
+		err = jsondiff.RenderUnified(&out, oldNode, newNode, opts)
+	default:
+		diffs := jsondiff.GetDiffNode(oldNode, newNode)
+		if len(diffs.Children) > 0 {
+			err = jsondiff.RenderPatch(&out, oldNode, diffs, opts)
+		}
+	}
+	if err != nil {
+		return fail(stderr, err)
+	}
+	if out.Len() == 0 {
+		return exitEqual
+	}
+	fmt.Fprintf(stdout, "diff --git a/%s b/%s\n", path, newPath)
+	if _, err := out.WriteTo(stdout); err != nil {
+		return fail(stderr, err)
+	}
+	return exitEqual
+}
+
+// runTextconv 以规范形式（key 按字典序排列）格式化 FILE，用作 diff.<driver>.textconv，
+// 使 git 的逐行比较不受 key 的顺序和格式的影响。FILE 不是合法的 JSON 时原样输出
+func runTextconv(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("textconv [-indent s] FILE", stderr)
+	indent := fs.String("indent", "  ", "indent the output with this string, empty for compact output")
+	if !parseFlags(fs, args, 1) {
+		return exitError
+	}
+	data, err := newInputs(stdin).readBytes(fs.Arg(0))
+	if err != nil {
+		return fail(stderr, err)
+	}
+	node, err := decode.Unmarshal(data)
+	if err != nil {
+		if _, err := stdout.Write(data); err != nil {
+			return fail(stderr, err)
+		}
+		return exitEqual
+	}
+	data, err = decode.MarshalCanonical(node)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	if *indent != "" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", *indent); err != nil {
+			return fail(stderr, err)
+		}
+		data = buf.Bytes()
+	}
+	if _, err := stdout.Write(append(data, '\n')); err != nil {
+		return fail(stderr, err)
+	}
+	return exitEqual
+}
+
+func readGitFile(name string) ([]byte, error) {
+	if name == devNull {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(name)
+	return data, errors.WithStack(err)
+}
+
+// parseGitFile 解析 git 传入的文件，/dev/null 表示文件不存在，返回 nil
+func parseGitFile(name string, data []byte) (*decode.JsonNode, error) {
+	if name == devNull {
+		return nil, nil
+	}
+	return decode.Unmarshal(data)
+}
+
+func gitName(prefix, path, file string) string {
+	if file == devNull {
+		return devNull
+	}
+	return prefix + path
+}
+
+// writeTextDiff 把 oldData 的所有行作为删除，newData 的所有行作为新增输出
+func writeTextDiff(w io.Writer, opts *jsondiff.RenderOptions, oldData, newData []byte) {
+	oldLines, newLines := splitLines(oldData), splitLines(newData)
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", opts.FromName, opts.ToName)
+	fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(len(oldLines)), hunkRange(len(newLines)))
+	for _, line := range oldLines {
+		fmt.Fprintf(w, "-%s\n", line)
+	}
+	for _, line := range newLines {
+		fmt.Fprintf(w, "+%s\n", line)
+	}
+}
+
+func splitLines(data []byte) []string {
+	s := strings.TrimSuffix(string(data), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func hunkRange(n int) string {
+	if n == 0 {
+		return "0,0"
+	}
+	return fmt.Sprintf("1,%d", n)
+}
+
+var mergeStrategies = map[string]jsondiff.MergeStrategy{
+	"fail":   jsondiff.MergeStrategyFail,
+	"ours":   jsondiff.MergeStrategyOurs,
+	"theirs": jsondiff.MergeStrategyTheirs,
+}
+
+// runGitMerge 实现 git 的合并驱动（merge.<driver>.driver），参数为 %O %A %B [%P]，
+// 使用 Merge3 以 %O 为共同祖先合并 %A 和 %B，并把结果写回 %A。
+// 冲突的路径输出到标准错误，并在指定 -conflicts 时以 JSON 格式写入该文件。
+// 使用 -strategy fail（默认）时，%A 中与冲突有关的行用 git 风格的冲突标记包围，标记之间分别是
+// 使用 %A 和 %B 的值解决冲突后的内容，返回 1 交给用户处理；这样的 %A 不是合法的 JSON，不会被误认为已经解决。
+// 文件不是合法的 JSON 时退回到 git merge-file 的逐行合并
+func runGitMerge(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("git-merge [-strategy fail|ours|theirs] [-conflicts FILE] [-indent s] BASE OURS THEIRS [PATH]", stderr)
+	strategyName := fs.String("strategy", "fail",
+		"how to resolve conflicts: fail (write conflict markers to OURS and exit 1), ours or theirs")
+	conflictsFile := fs.String("conflicts", "", "write the conflicts as JSON to this file")
+	indent := fs.String("indent", "  ", "indent the result with this string, empty for compact output")
+	if !parseFlags(fs, args, -1) {
+		return exitError
+	}
+	strategy, ok := mergeStrategies[*strategyName]
+	if !ok || fs.NArg() != 3 && fs.NArg() != 4 {
+		fs.Usage()
+		return exitError
+	}
+	baseFile, oursFile, theirsFile := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+	path := fs.Arg(3)
+	if path == "" {
+		path = oursFile
+	}
+	var nodes [3]*decode.JsonNode
+	for i, name := range []string{baseFile, oursFile, theirsFile} {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return fail(stderr, errors.WithStack(err))
+		}
+		// 两个分支都添加了同一个文件时 git 传入的共同祖先是空文件
+		if i == 0 && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if nodes[i], err = decode.Unmarshal(data); err != nil {
+			fmt.Fprintf(stderr, "jsondiff: %s is not valid JSON, falling back to git merge-file\n", path)
+			return gitMergeFile(baseFile, oursFile, theirsFile, path, stderr)
+		}
+	}
+	failOnConflict := strategy == jsondiff.MergeStrategyFail
+	if failOnConflict {
+		// 先使用 ours 的值解决冲突，有冲突时再与使用 theirs 的值解决的结果比较，生成冲突标记
+		strategy = jsondiff.MergeStrategyOurs
+	}
+	merged, conflicts, err := jsondiff.Merge3(nodes[0], nodes[1], nodes[2], &jsondiff.Merge3Options{Strategy: strategy})
+	if err != nil {
+		return fail(stderr, err)
+	}
+	var buf bytes.Buffer
+	if err := write(&buf, merged, *indent); err != nil {
+		return fail(stderr, err)
+	}
+	result := buf.Bytes()
+	if failOnConflict && len(conflicts) > 0 {
+		theirs, _, err := jsondiff.Merge3(nodes[0], nodes[1], nodes[2], &jsondiff.Merge3Options{Strategy: jsondiff.MergeStrategyTheirs})
+		if err != nil {
+			return fail(stderr, err)
+		}
+		var theirsBuf bytes.Buffer
+		if err := write(&theirsBuf, theirs, *indent); err != nil {
+			return fail(stderr, err)
+		}
+		result = conflictMarkers(result, theirsBuf.Bytes(), path, "theirs")
+	}
+	if err := ioutil.WriteFile(oursFile, result, 0644); err != nil {
+		return fail(stderr, errors.WithStack(err))
+	}
+	for _, c := range conflicts {
+		fmt.Fprintf(stderr, "CONFLICT (json): %s at %q: base %s, ours %s, theirs %s\n",
+			path, c.Path, conflictValue(c.Base), conflictValue(c.Ours), conflictValue(c.Theirs))
+	}
+	if *conflictsFile != "" && len(conflicts) > 0 {
+		if err := writeFile(*conflictsFile, conflictsNode(conflicts), "  "); err != nil {
+			return fail(stderr, err)
+		}
+	}
+	if len(conflicts) > 0 && failOnConflict {
+		return exitDifferent
+	}
+	return exitEqual
+}
+
+// gitMergeFile 使用 git merge-file 逐行合并，冲突标记写入 ours
+func gitMergeFile(baseFile, oursFile, theirsFile, path string, stderr io.Writer) int {
+	cmd := exec.Command("git", "merge-file", "-L", path, "-L", "base", "-L", "theirs", oursFile, baseFile, theirsFile)
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+		return exitDifferent
+	}
+	if err != nil {
+		return fail(stderr, errors.Wrap(err, "fail to run git merge-file"))
+	}
+	return exitEqual
+}
+
+// maxMarkerCells 限制 conflictMarkers 逐行比较时的计算量，超过时把所有不同的行放在同一个冲突块中
+const maxMarkerCells = 1 << 22
+
+// conflictMarkers 逐行比较分别使用 ours 和 theirs 的值解决冲突后的两个结果，相同的行只保留一份，
+// 不同的行用 git 风格的冲突标记包围
+func conflictMarkers(ours, theirs []byte, oursLabel, theirsLabel string) []byte {
+	a, b := splitLines(ours), splitLines(theirs)
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var buf bytes.Buffer
+	var oursLines, theirsLines []string
+	flush := func() {
+		if len(oursLines) == 0 && len(theirsLines) == 0 {
+			return
+		}
+		fmt.Fprintf(&buf, "<<<<<<< %s\n", oursLabel)
+		writeLines(&buf, oursLines)
+		buf.WriteString("=======\n")
+		writeLines(&buf, theirsLines)
+		fmt.Fprintf(&buf, ">>>>>>> %s\n", theirsLabel)
+		oursLines, theirsLines = nil, nil
+	}
+	writeLines(&buf, a[:prefix])
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(ma)*len(mb) > maxMarkerCells {
+		oursLines, theirsLines = ma, mb
+	} else {
+		// dp[i][j] 是 ma[i:] 和 mb[j:] 的最长公共子序列的长度
+		dp := make([][]int, len(ma)+1)
+		for i := range dp {
+			dp[i] = make([]int, len(mb)+1)
+		}
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					dp[i][j] = dp[i+1][j+1] + 1
+				} else if dp[i+1][j] >= dp[i][j+1] {
+					dp[i][j] = dp[i+1][j]
+				} else {
+					dp[i][j] = dp[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(ma) || j < len(mb) {
+			switch {
+			case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+				flush()
+				writeLines(&buf, ma[i:i+1])
+				i++
+				j++
+			case j == len(mb) || i < len(ma) && dp[i+1][j] >= dp[i][j+1]:
+				oursLines = append(oursLines, ma[i])
+				i++
+			default:
+				theirsLines = append(theirsLines, mb[j])
+				j++
+			}
+		}
+	}
+	flush()
+	writeLines(&buf, a[len(a)-suffix:])
+	return buf.Bytes()
+}
+
+func writeLines(buf *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+}
+
+func writeFile(name string, node *decode.JsonNode, indent string) error {
+	var buf bytes.Buffer
+	if err := write(&buf, node, indent); err != nil {
+		return err
+	}
+	return errors.WithStack(ioutil.WriteFile(name, buf.Bytes(), 0644))
+}
+
+func conflictValue(node *decode.JsonNode) string {
+	if node == nil {
+		return "(missing)"
+	}
+	data, err := decode.Marshal(node)
+	if err != nil {
+		return "(invalid)"
+	}
+	return string(data)
+}
+
+// conflictsNode 把冲突转换为 JSON 数组，不存在的值对应的 key 会被省略
+func conflictsNode(conflicts []jsondiff.Conflict) *decode.JsonNode {
+	res := decode.NewSliceNode(make([]*decode.JsonNode, 0, len(conflicts)), 0)
+	for _, c := range conflicts {
+		node := decode.NewObjectNode("", map[string]*decode.JsonNode{}, 0)
+		_ = node.ADD("path", decode.NewValueNode(c.Path, 0))
+		for _, kv := range []struct {
+			key   string
+			value *decode.JsonNode
+		}{{"base", c.Base}, {"ours", c.Ours}, {"theirs", c.Theirs}} {
+			if kv.value != nil {
+				_ = node.ADD(kv.key, kv.value)
+			}
+		}
+		res.Children = append(res.Children, node)
+	}
+	return res
+}
@@ -0,0 +1,366 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// jsondiff 是 json-diff 的命令行工具，用于在脚本和 CI 中比较、合并 JSON 文件。
+//
+// 用法：
+//
+//	jsondiff diff [-move] [-copy] [-full-remove] [-indent s] SOURCE TARGET
+//	jsondiff patch [-lenient] [-indent s] SOURCE PATCH
+//	jsondiff merge-patch [-indent s] SOURCE PATCH
+//	jsondiff fmt [-indent s] [FILE]
+//	jsondiff validate [FILE...]
+//	jsondiff git-diff [-color] [-context n] PATH OLD-FILE OLD-HEX OLD-MODE NEW-FILE NEW-HEX NEW-MODE
+//	jsondiff textconv [-indent s] FILE
+//	jsondiff git-merge [-strategy fail|ours|theirs] [-conflicts FILE] [-indent s] BASE OURS THEIRS [PATH]
+//
+// 文件名为 - 或省略时从标准输入读取。与 diff(1) 相同，退出码 0 表示没有差异，
+// 1 表示有差异（validate 中表示有不合法的文件），2 表示出错。git-diff 和 textconv 用于 git 的差异驱动，
+// 成功时总是返回 0；git-merge 用于 git 的合并驱动，返回 1 表示有冲突，此时 OURS 中写入了 git 风格的冲突标记。
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	jsondiff "github.com/520MianXiangDuiXiang520/json-diff"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	exitEqual     = 0
+	exitDifferent = 1
+	exitError     = 2
+)
+
+const usage = `usage: jsondiff <command> [flags] [args]
+
+commands:
+  diff         print the RFC 6902 patch from SOURCE to TARGET
+  patch        apply an RFC 6902 PATCH to SOURCE
+  merge-patch  apply an RFC 7396 merge PATCH to SOURCE
+  fmt          pretty-print a JSON document
+  validate     check that the files are valid JSON
+  git-diff     structural diff for git's external diff protocol
+  textconv     print a JSON document in canonical form for git's textconv
+  git-merge    three-way merge driver for git
+
+Use "-" to read a file from stdin. Run "jsondiff <command> -h" for the flags of a command.
+`
+
+type command func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"diff":        runDiff,
+	"patch":       runPatch,
+	"merge-patch": runMergePatch,
+	"fmt":         runFmt,
+	"validate":    runValidate,
+	"git-diff":    runGitDiff,
+	"textconv":    runTextconv,
+	"git-merge":   runGitMerge,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+	if args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		fmt.Fprint(stdout, usage)
+		return exitEqual
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "jsondiff: unknown command %q\n\n%s", args[0], usage)
+		return exitError
+	}
+	return cmd(args[1:], stdin, stdout, stderr)
+}
+
+func runDiff(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("diff SOURCE TARGET", stderr)
+	move := fs.Bool("move", false, "use move operations for moved values")
+	cp := fs.Bool("copy", false, "use copy operations for duplicated values")
+	fullRemove := fs.Bool("full-remove", false, "include the removed value in remove operations")
+	indent := fs.String("indent", "", "indent the output with this string")
+	if !parseFlags(fs, args, 2) {
+		return exitError
+	}
+	in := newInputs(stdin)
+	source, err := in.readDocument(fs.Arg(0))
+	if err != nil {
+		return fail(stderr, err)
+	}
+	target, err := in.readDocument(fs.Arg(1))
+	if err != nil {
+		return fail(stderr, err)
+	}
+	var options []jsondiff.DiffOption
+	if *move {
+		options = append(options, jsondiff.UseMoveOption)
+	}
+	if *cp {
+		options = append(options, jsondiff.UseCopyOption)
+	}
+	if *fullRemove {
+		options = append(options, jsondiff.UseFullRemoveOption)
+	}
+	diffs, err := jsondiff.AsDiffs(source, target, options...)
+	if err != nil {
+		return fail(stderr, errors.Wrapf(err, "fail to diff %s and %s", displayName(fs.Arg(0)), displayName(fs.Arg(1))))
+	}
+	if err := writeBytes(stdout, diffs, *indent); err != nil {
+		return fail(stderr, err)
+	}
+	if string(diffs) == "[]" {
+		return exitEqual
+	}
+	return exitDifferent
+}
+
+func runPatch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("patch SOURCE PATCH", stderr)
+	lenient := fs.Bool("lenient", false, "apply the patch in lenient mode")
+	indent := fs.String("indent", "", "indent the output with this string")
+	if !parseFlags(fs, args, 2) {
+		return exitError
+	}
+	in := newInputs(stdin)
+	source, err := in.readDocument(fs.Arg(0))
+	if err != nil {
+		return fail(stderr, err)
+	}
+	patch, err := in.readDocument(fs.Arg(1))
+	if err != nil {
+		return fail(stderr, err)
+	}
+	var options []jsondiff.DiffOption
+	if *lenient {
+		options = append(options, jsondiff.UseLenientMergeOption)
+	}
+	res, err := jsondiff.MergeDiff(source, patch, options...)
+	if err != nil {
+		return fail(stderr, errors.Wrapf(err, "fail to apply %s to %s", displayName(fs.Arg(1)), displayName(fs.Arg(0))))
+	}
+	if err := writeBytes(stdout, res, *indent); err != nil {
+		return fail(stderr, err)
+	}
+	return exitEqual
+}
+
+func runMergePatch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("merge-patch SOURCE PATCH", stderr)
+	indent := fs.String("indent", "", "indent the output with this string")
+	if !parseFlags(fs, args, 2) {
+		return exitError
+	}
+	in := newInputs(stdin)
+	source, err := in.read(fs.Arg(0))
+	if err != nil {
+		return fail(stderr, err)
+	}
+	patch, err := in.read(fs.Arg(1))
+	if err != nil {
+		return fail(stderr, err)
+	}
+	res, err := jsondiff.ApplyMergePatchNode(source, patch)
+	if err != nil {
+		return fail(stderr, err)
+	}
+	if err := write(stdout, res, *indent); err != nil {
+		return fail(stderr, err)
+	}
+	return exitEqual
+}
+
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("fmt [FILE]", stderr)
+	indent := fs.String("indent", "  ", "indent the output with this string, empty for compact output")
+	if !parseFlags(fs, args, -1) {
+		return exitError
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitError
+	}
+	node, err := newInputs(stdin).read(fs.Arg(0))
+	if err != nil {
+		return fail(stderr, err)
+	}
+	if err := write(stdout, node, *indent); err != nil {
+		return fail(stderr, err)
+	}
+	return exitEqual
+}
+
+func runValidate(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("validate [FILE...]", stderr)
+	if !parseFlags(fs, args, -1) {
+		return exitError
+	}
+	names := fs.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	in := newInputs(stdin)
+	code := exitEqual
+	for _, name := range names {
+		data, err := in.readBytes(name)
+		if err != nil {
+			fail(stderr, err)
+			return exitError
+		}
+		if _, err := parse(data); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", displayName(name), err)
+			code = exitDifferent
+		}
+	}
+	return code
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: jsondiff %s\n", name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags 解析参数并检查位置参数的个数，nArg 小于 0 时不检查
+func parseFlags(fs *flag.FlagSet, args []string, nArg int) bool {
+	if err := fs.Parse(args); err != nil {
+		return false
+	}
+	if nArg >= 0 && fs.NArg() != nArg {
+		fs.Usage()
+		return false
+	}
+	return true
+}
+
+// inputs 读取命令行中的文件，保证标准输入最多只被读取一次
+type inputs struct {
+	stdin     io.Reader
+	stdinUsed bool
+}
+
+func newInputs(stdin io.Reader) *inputs {
+	return &inputs{stdin: stdin}
+}
+
+func (in *inputs) readBytes(name string) ([]byte, error) {
+	if name != "" && name != "-" {
+		data, err := ioutil.ReadFile(name)
+		return data, errors.WithStack(err)
+	}
+	if in.stdinUsed {
+		return nil, errors.New("stdin can only be read once")
+	}
+	in.stdinUsed = true
+	data, err := ioutil.ReadAll(in.stdin)
+	return data, errors.Wrap(err, "fail to read stdin")
+}
+
+// readDocument 读取一个 JSON 文档的原始内容，空文档（只包含空白）视为错误
+func (in *inputs) readDocument(name string) ([]byte, error) {
+	data, err := in.readBytes(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, errors.Wrapf(errEmptyDocument, "fail to parse %s", displayName(name))
+	}
+	return data, nil
+}
+
+func (in *inputs) read(name string) (*decode.JsonNode, error) {
+	data, err := in.readBytes(name)
+	if err != nil {
+		return nil, err
+	}
+	node, err := parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fail to parse %s", displayName(name))
+	}
+	return node, nil
+}
+
+var errEmptyDocument = errors.New("empty document")
+
+// parse 解析一个 JSON 文档，decode.Unmarshal 把空文档解析为 nil，这里视为错误
+func parse(data []byte) (*decode.JsonNode, error) {
+	node, err := decode.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, errEmptyDocument
+	}
+	return node, nil
+}
+
+func displayName(name string) string {
+	if name == "" || name == "-" {
+		return "<stdin>"
+	}
+	return name
+}
+
+func write(w io.Writer, node *decode.JsonNode, indent string) error {
+	var data []byte
+	var err error
+	if indent == "" {
+		data, err = decode.Marshal(node)
+	} else {
+		data, err = decode.MarshalIndent(node, "", indent)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return errors.WithStack(err)
+}
+
+// writeBytes 把已经序列化的 JSON 写入 w，indent 不为空时重新缩进
+func writeBytes(w io.Writer, data []byte, indent string) error {
+	if indent != "" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", indent); err != nil {
+			return errors.WithStack(err)
+		}
+		data = buf.Bytes()
+	}
+	_, err := w.Write(append(data, '\n'))
+	return errors.WithStack(err)
+}
+
+func fail(stderr io.Writer, err error) int {
+	fmt.Fprintf(stderr, "jsondiff: %v\n", err)
+	return exitError
+}
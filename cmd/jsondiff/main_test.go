@@ -0,0 +1,648 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var binary string
+
+const fixtures = "../../test_data/jsondiff/"
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "jsondiff")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	binary = filepath.Join(dir, "jsondiff")
+	out, err := exec.Command("go", "build", "-o", binary, ".").CombinedOutput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fail to build jsondiff: %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// runJsondiff 执行命令，返回标准输出、标准错误和退出码
+func runJsondiff(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = fixtures
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return stdout.String(), stderr.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("fail to run jsondiff: %v", err)
+	}
+	return stdout.String(), stderr.String(), 0
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		want     string
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name:     "diff",
+			args:     []string{"diff", "source.json", "target.json"},
+			want:     `[{"op":"replace","path":"/version","value":2},{"op":"add","path":"/tags/2","value":"patch"},{"op":"remove","path":"/owner"},{"op":"add","path":"/maintainer","value":{"id":1,"name":"Junebao"}}]` + "\n",
+			wantCode: exitDifferent,
+		},
+		{
+			name:     "diff with move",
+			args:     []string{"diff", "-move", "source.json", "target.json"},
+			want:     `[{"op":"replace","path":"/version","value":2},{"op":"add","path":"/tags/2","value":"patch"},{"op":"move","path":"/maintainer","from":"/owner"}]` + "\n",
+			wantCode: exitDifferent,
+		},
+		{
+			name:     "diff with full remove",
+			args:     []string{"diff", "-full-remove", "source.json", "target.json"},
+			want:     `[{"op":"replace","path":"/version","value":2},{"op":"add","path":"/tags/2","value":"patch"},{"op":"remove","path":"/owner","value":{"id":1,"name":"Junebao"}},{"op":"add","path":"/maintainer","value":{"id":1,"name":"Junebao"}}]` + "\n",
+			wantCode: exitDifferent,
+		},
+		{
+			name:     "diff indent",
+			args:     []string{"diff", "-move", "-indent", " ", "source.json", "target.json"},
+			want:     "[\n {\n  \"op\": \"replace\",\n  \"path\": \"/version\",\n  \"value\": 2\n },\n {\n  \"op\": \"add\",\n  \"path\": \"/tags/2\",\n  \"value\": \"patch\"\n },\n {\n  \"op\": \"move\",\n  \"path\": \"/maintainer\",\n  \"from\": \"/owner\"\n }\n]\n",
+			wantCode: exitDifferent,
+		},
+		{
+			name:     "diff equal from stdin",
+			args:     []string{"diff", "-", "source.json"},
+			stdin:    `{"owner": {"name": "Junebao", "id": 1}, "tags": ["json", "diff"], "version": 1, "name": "json-diff"}`,
+			want:     "[]\n",
+			wantCode: exitEqual,
+		},
+		{
+			name:     "diff stdin twice",
+			args:     []string{"diff", "-", "-"},
+			stdin:    `{}`,
+			wantCode: exitError,
+			wantErr:  "stdin can only be read once",
+		},
+		{
+			name:     "diff invalid",
+			args:     []string{"diff", "source.json", "invalid.json"},
+			wantCode: exitError,
+			wantErr:  "fail to diff source.json and invalid.json: fail to unmarshal tar",
+		},
+		{
+			name:     "diff empty",
+			args:     []string{"diff", "empty.json", "source.json"},
+			wantCode: exitError,
+			wantErr:  "fail to parse empty.json: empty document",
+		},
+		{
+			name:     "diff missing file",
+			args:     []string{"diff", "source.json", "missing.json"},
+			wantCode: exitError,
+			wantErr:  "missing.json",
+		},
+		{
+			name:     "diff wrong arguments",
+			args:     []string{"diff", "source.json"},
+			wantCode: exitError,
+			wantErr:  "usage: jsondiff diff",
+		},
+		{
+			name: "patch",
+			args: []string{"patch", "source.json", "patch.json"},
+			want: `{"name":"json-diff","version":2,"tags":["json","diff","patch"],"owner":{"id":1,"name":"Junebao"}}` + "\n",
+		},
+		{
+			name:     "patch failed",
+			args:     []string{"patch", "source.json", "bad_patch.json"},
+			wantCode: exitError,
+			wantErr:  "path not found",
+		},
+		{
+			name:     "patch invalid",
+			args:     []string{"patch", "source.json", "invalid.json"},
+			wantCode: exitError,
+			wantErr:  "fail to apply invalid.json to source.json: fail to unmarshal diff data",
+		},
+		{
+			name:     "patch empty",
+			args:     []string{"patch", "source.json", "-"},
+			stdin:    " \n",
+			wantCode: exitError,
+			wantErr:  "fail to parse <stdin>: empty document",
+		},
+		{
+			name:     "patch strict",
+			args:     []string{"patch", "source.json", "-"},
+			stdin:    `[{"op": "replace", "path": "/license", "value": "MIT"}]`,
+			wantCode: exitError,
+			wantErr:  "path not found",
+		},
+		{
+			name:  "patch lenient",
+			args:  []string{"patch", "-lenient", "source.json", "-"},
+			stdin: `[{"op": "replace", "path": "/license", "value": "MIT"}]`,
+			want:  `{"name":"json-diff","version":1,"tags":["json","diff"],"owner":{"id":1,"name":"Junebao"},"license":"MIT"}` + "\n",
+		},
+		{
+			name: "merge-patch",
+			args: []string{"merge-patch", "source.json", "merge_patch.json"},
+			want: `{"name":"json-diff","version":2,"tags":["json","diff"],"license":"Apache-2.0"}` + "\n",
+		},
+		{
+			name:     "merge-patch empty",
+			args:     []string{"merge-patch", "source.json", "empty.json"},
+			wantCode: exitError,
+			wantErr:  "fail to parse empty.json: empty document",
+		},
+		{
+			name:  "fmt",
+			args:  []string{"fmt"},
+			stdin: `{"b": [1, {}], "a": 1.50}`,
+			want:  "{\n  \"b\": [\n    1,\n    {}\n  ],\n  \"a\": 1.50\n}\n",
+		},
+		{
+			name:  "fmt compact",
+			args:  []string{"fmt", "-indent=", "-"},
+			stdin: "{\n  \"b\": [1, {}],\n  \"a\": 1\n}",
+			want:  `{"b":[1,{}],"a":1}` + "\n",
+		},
+		{
+			name:     "fmt empty",
+			args:     []string{"fmt", "empty.json"},
+			wantCode: exitError,
+			wantErr:  "fail to parse empty.json: empty document",
+		},
+		{
+			name: "validate",
+			args: []string{"validate", "source.json", "patch.json"},
+		},
+		{
+			name:     "validate invalid",
+			args:     []string{"validate", "source.json", "invalid.json"},
+			wantCode: exitDifferent,
+			wantErr:  "invalid.json: ",
+		},
+		{
+			name:     "validate empty",
+			args:     []string{"validate", "source.json", "empty.json"},
+			wantCode: exitDifferent,
+			wantErr:  "empty.json: empty document",
+		},
+		{
+			name: "git-diff",
+			args: []string{"git-diff", "x.json", "source.json", "1111111", "100644", "target.json", "2222222", "100644"},
+			want: `diff --git a/x.json b/x.json
+--- a/x.json
++++ b/x.json
+@@ -1,11 +1,12 @@
+ {
+   "name": "json-diff",
+-  "version": 1,
++  "version": 2,
+   "tags": [
+     "json",
+-    "diff"
++    "diff",
++    "patch"
+   ],
+-  "owner": {
++  "maintainer": {
+     "id": 1,
+     "name": "Junebao"
+   }
+`,
+		},
+		{
+			name: "git-diff reordered",
+			args: []string{"git-diff", "x.json", "source.json", "1111111", "100644", "reordered.json", "2222222", "100644"},
+		},
+		{
+			name: "git-diff new file",
+			args: []string{"git-diff", "-context=0", "x.json", "/dev/null", "0000000", "0", "merge_patch.json", "2222222", "100644"},
+			want: `diff --git a/x.json b/x.json
+--- /dev/null
++++ b/x.json
+@@ -0,0 +1,5 @@
++{
++  "version": 2,
++  "owner": null,
++  "license": "Apache-2.0"
++}
+`,
+		},
+		{
+			name: "git-diff renamed invalid file",
+			args: []string{"git-diff", "x.json", "invalid.json", "1111111", "100644", "bad_patch.json", "2222222", "100644", "y.json", "similarity index 0%"},
+			want: `diff --git a/x.json b/y.json
+--- a/x.json
++++ b/y.json
+@@ -1,1 +1,1 @@
+-{"a": [1, 2,
++[{"op": "remove", "path": "/missing"}]
+`,
+		},
+		{
+			name:     "git-diff wrong arguments",
+			args:     []string{"git-diff", "x.json", "source.json"},
+			wantCode: exitError,
+			wantErr:  "usage: jsondiff git-diff",
+		},
+		{
+			name: "textconv",
+			args: []string{"textconv", "reordered.json"},
+			want: `{
+  "name": "json-diff",
+  "owner": {
+    "id": 1,
+    "name": "Junebao"
+  },
+  "tags": [
+    "json",
+    "diff"
+  ],
+  "version": 1
+}
+`,
+		},
+		{
+			name: "textconv invalid",
+			args: []string{"textconv", "invalid.json"},
+			want: "{\"a\": [1, 2,\n",
+		},
+		{
+			name:     "unknown command",
+			args:     []string{"frobnicate"},
+			wantCode: exitError,
+			wantErr:  `unknown command "frobnicate"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runJsondiff(t, tt.stdin, tt.args...)
+			if code != tt.wantCode {
+				t.Errorf("want exit code %d, got %d, stderr: %s", tt.wantCode, code, stderr)
+			}
+			if stdout != tt.want {
+				t.Errorf("want stdout %q, got %q", tt.want, stdout)
+			}
+			if !strings.Contains(stderr, tt.wantErr) || tt.wantErr == "" && stderr != "" {
+				t.Errorf("want stderr containing %q, got %q", tt.wantErr, stderr)
+			}
+		})
+	}
+}
+
+// 使用 diff 生成的差异应当能通过 patch 还原出目标文档
+func TestDiffPatchRoundTrip(t *testing.T) {
+	diff, stderr, code := runJsondiff(t, "", "diff", "-move", "source.json", "target.json")
+	if code != exitDifferent {
+		t.Fatalf("want exit code %d, got %d, stderr: %s", exitDifferent, code, stderr)
+	}
+	got, stderr, code := runJsondiff(t, diff, "patch", "source.json", "-")
+	if code != exitEqual {
+		t.Fatalf("want exit code %d, got %d, stderr: %s", exitEqual, code, stderr)
+	}
+	_, stderr, code = runJsondiff(t, got, "diff", "-", "target.json")
+	if code != exitEqual {
+		t.Errorf("the patched document differs from target.json: %s", stderr)
+	}
+}
+
+// 通过 git diff 的外部差异程序调用 git-diff
+func TestGitDiff_git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	cmd := exec.Command("git", "diff", "--no-index", "--ext-diff", "source.json", "reordered.json")
+	cmd.Dir = fixtures
+	cmd.Env = append(os.Environ(), "GIT_EXTERNAL_DIFF="+binary+" git-diff")
+	out, err := cmd.Output()
+	// 文本不同时 git diff --no-index 返回 1
+	if exitErr, ok := err.(*exec.ExitError); err != nil && (!ok || exitErr.ExitCode() != 1) {
+		t.Fatalf("fail to run git diff: %v", err)
+	}
+	if len(out) != 0 {
+		t.Errorf("want no structural difference, got:\n%s", out)
+	}
+
+	cmd = exec.Command("git", "diff", "--no-index", "--ext-diff", "source.json", "target.json")
+	cmd.Dir = fixtures
+	cmd.Env = append(os.Environ(), "GIT_EXTERNAL_DIFF="+binary+" git-diff")
+	out, _ = cmd.Output()
+	if !strings.Contains(string(out), "-  \"version\": 1,\n+  \"version\": 2,\n") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+// mergeFixtures 把 test_data/jsondiff/merge 中的文件复制到临时目录，返回目录和清理函数
+func mergeFixtures(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "jsondiff-merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(fixtures + "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(fixtures, "merge", f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, f.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestGitMerge(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		files map[string]string
+		// ours 是写入合并结果的文件，为空时使用 ours.json
+		ours string
+		want string
+		// wantText 不为空时逐字比较写入 ours 的内容，用于检查冲突标记
+		wantText string
+		wantCode int
+		wantErr  []string
+		// wantConflicts 不为空时检查 conflicts.json 的内容
+		wantConflicts string
+	}{
+		{
+			name: "clean",
+			args: []string{"base.json", "ours.json", "theirs.json", "package.json"},
+			want: `{"name":"json-diff","version":"1.1.0","dependencies":{"errors":"0.9.1","bytebufferpool":"1.0.0","testify":"1.8.0"},` +
+				`"scripts":{"test":"go test -race ./...","vet":"go vet ./..."}}`,
+		},
+		{
+			name: "conflict",
+			args: []string{"-conflicts", "conflicts.json", "base.json", "ours.json", "conflict.json", "package.json"},
+			wantText: "{\n  \"name\": \"json-diff\",\n<<<<<<< package.json\n  \"version\": \"1.1.0\",\n=======\n  \"version\": \"2.0.0\",\n>>>>>>> theirs\n" +
+				"  \"dependencies\": {\n    \"errors\": \"0.9.1\",\n    \"bytebufferpool\": \"1.0.0\"\n  }\n}\n",
+			wantCode: exitDifferent,
+			wantErr: []string{
+				`CONFLICT (json): package.json at "/version": base "1.0.0", ours "1.1.0", theirs "2.0.0"`,
+			},
+			wantConflicts: `[{"path":"/version","base":"1.0.0","ours":"1.1.0","theirs":"2.0.0"}]`,
+		},
+		{
+			name:    "theirs",
+			args:    []string{"-strategy", "theirs", "base.json", "ours.json", "conflict.json"},
+			want:    `{"name":"json-diff","version":"2.0.0","dependencies":{"errors":"0.9.1","bytebufferpool":"1.0.0"}}`,
+			wantErr: []string{`ours.json at "/version"`},
+		},
+		{
+			name:  "added on both sides",
+			args:  []string{"-indent=", "empty.json", "a.json", "b.json"},
+			files: map[string]string{"empty.json": "", "a.json": `{"a": 1, "c": 1}`, "b.json": `{"b": 1, "c": 1}`},
+			ours:  "a.json",
+			want:  `{"a":1,"c":1,"b":1}`,
+		},
+		{
+			name:  "removed and modified",
+			args:  []string{"-conflicts", "conflicts.json", "base.json", "a.json", "b.json"},
+			files: map[string]string{"a.json": `{"name": "json-diff"}`, "b.json": `{"name": "json-diff", "scripts": {"test": "go test -v"}}`},
+			ours:  "a.json",
+			wantText: "{\n<<<<<<< a.json\n  \"name\": \"json-diff\"\n=======\n  \"name\": \"json-diff\",\n" +
+				"  \"scripts\": {\n    \"test\": \"go test -v\"\n  }\n>>>>>>> theirs\n}\n",
+			wantCode:      exitDifferent,
+			wantErr:       []string{`a.json at "/scripts": base {"test":"go test ./..."}, ours (missing), theirs {"test":"go test -v"}`},
+			wantConflicts: `[{"path":"/scripts","base":{"test":"go test ./..."},"theirs":{"test":"go test -v"}}]`,
+		},
+		{
+			name: "arrays",
+			args: []string{"-indent=", "o.json", "a.json", "b.json"},
+			files: map[string]string{
+				"o.json": `{"deps": ["a", "b"]}`,
+				"a.json": `{"deps": ["a", "b", "c"]}`,
+				"b.json": `{"deps": ["z", "b"]}`,
+			},
+			ours: "a.json",
+			want: `{"deps":["z","b","c"]}`,
+		},
+		{
+			name: "inserted into the same position of an array",
+			args: []string{"o.json", "a.json", "b.json"},
+			files: map[string]string{
+				"o.json": `{"deps": ["a", "b"]}`,
+				"a.json": `{"deps": ["a", "c", "b"]}`,
+				"b.json": `{"deps": ["a", "d", "b"]}`,
+			},
+			ours:     "a.json",
+			wantText: "{\n  \"deps\": [\n    \"a\",\n<<<<<<< a.json\n    \"c\",\n=======\n    \"d\",\n>>>>>>> theirs\n    \"b\"\n  ]\n}\n",
+			wantCode: exitDifferent,
+			wantErr:  []string{`a.json at "/deps/1": base (missing), ours ["c"], theirs ["d"]`},
+		},
+		{
+			name:     "unknown strategy",
+			args:     []string{"-strategy", "union", "base.json", "ours.json", "theirs.json"},
+			wantCode: exitError,
+			wantErr:  []string{"usage: jsondiff git-merge"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, cleanup := mergeFixtures(t)
+			defer cleanup()
+			for name, content := range tt.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cmd := exec.Command(binary, append([]string{"git-merge"}, tt.args...)...)
+			cmd.Dir = dir
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+			code := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if code != tt.wantCode {
+				t.Errorf("want exit code %d, got %d, stderr: %s", tt.wantCode, code, stderr.String())
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(stderr.String(), want) {
+					t.Errorf("want stderr containing %q, got %q", want, stderr.String())
+				}
+			}
+			if tt.want != "" || tt.wantText != "" {
+				ours := tt.ours
+				if ours == "" {
+					ours = "ours.json"
+				}
+				got, err := ioutil.ReadFile(filepath.Join(dir, ours))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.wantText != "" && string(got) != tt.wantText {
+					t.Errorf("want:\n%s\ngot:\n%s", tt.wantText, got)
+				}
+				if tt.want != "" && compact(t, got) != tt.want {
+					t.Errorf("want %s, got %s", tt.want, got)
+				}
+			}
+			if tt.wantConflicts != "" {
+				got, err := ioutil.ReadFile(filepath.Join(dir, "conflicts.json"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if compact(t, got) != tt.wantConflicts {
+					t.Errorf("want conflicts %s, got %s", tt.wantConflicts, got)
+				}
+			}
+		})
+	}
+}
+
+// compact 去掉 JSON 中的空白
+func compact(t *testing.T, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		t.Fatalf("%s is not valid JSON: %v", data, err)
+	}
+	return buf.String()
+}
+
+func TestGitMerge_invalid(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, cleanup := mergeFixtures(t)
+	defer cleanup()
+	files := map[string]string{
+		"base.txt":   "{\n\"a\": 1,\n",
+		"ours.txt":   "{\n\"a\": 2,\n",
+		"theirs.txt": "{\n\"a\": 3,\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(binary, "git-merge", "base.txt", "ours.txt", "theirs.txt", "x.json")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != exitDifferent {
+		t.Fatalf("want exit code %d, got %v: %s", exitDifferent, err, out)
+	}
+	if !strings.Contains(string(out), "x.json is not valid JSON") {
+		t.Errorf("unexpected output %s", out)
+	}
+	got, _ := ioutil.ReadFile(filepath.Join(dir, "ours.txt"))
+	if !strings.Contains(string(got), "<<<<<<< x.json") {
+		t.Errorf("want conflict markers, got %s", got)
+	}
+}
+
+// 在临时仓库中通过 .gitattributes 使用 git-merge 合并两个分支
+func TestGitMerge_git(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, cleanup := mergeFixtures(t)
+	defer cleanup()
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com",
+			"-c", "merge.jsondiff.driver=" + binary + " git-merge %O %A %B %P"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+	copyFile := func(from, to string) {
+		data, err := ioutil.ReadFile(filepath.Join(dir, from))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, to), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("package.json merge=jsondiff\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	copyFile("base.json", "package.json")
+	steps := [][]string{
+		{"init", "-q"},
+		{"checkout", "-q", "-b", "main"},
+		{"add", ".gitattributes", "package.json"},
+		{"commit", "-q", "-m", "base"},
+		{"checkout", "-q", "-b", "theirs"},
+	}
+	for _, step := range steps {
+		if out, err := git(step...); err != nil {
+			t.Fatalf("git %v: %v\n%s", step, err, out)
+		}
+	}
+	copyFile("theirs.json", "package.json")
+	for _, step := range [][]string{{"commit", "-q", "-am", "theirs"}, {"checkout", "-q", "main"}} {
+		if out, err := git(step...); err != nil {
+			t.Fatalf("git %v: %v\n%s", step, err, out)
+		}
+	}
+	copyFile("ours.json", "package.json")
+	if out, err := git("commit", "-q", "-am", "ours"); err != nil {
+		t.Fatalf("git commit: %v\n%s", err, out)
+	}
+	if out, err := git("merge", "-q", "--no-edit", "theirs"); err != nil {
+		t.Fatalf("git merge: %v\n%s", err, out)
+	}
+	got, _ := ioutil.ReadFile(filepath.Join(dir, "package.json"))
+	want := `{"name":"json-diff","version":"1.1.0","dependencies":{"errors":"0.9.1","bytebufferpool":"1.0.0","testify":"1.8.0"},` +
+		`"scripts":{"test":"go test -race ./...","vet":"go vet ./..."}}`
+	if compact(t, got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestConflictMarkers(t *testing.T) {
+	ours := "{\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3,\n  \"d\": 4\n}\n"
+	theirs := "{\n  \"a\": 9,\n  \"b\": 2,\n  \"c\": 3,\n  \"d\": 8,\n  \"e\": 5\n}\n"
+	want := "{\n<<<<<<< ours\n  \"a\": 1,\n=======\n  \"a\": 9,\n>>>>>>> theirs\n  \"b\": 2,\n  \"c\": 3,\n" +
+		"<<<<<<< ours\n  \"d\": 4\n=======\n  \"d\": 8,\n  \"e\": 5\n>>>>>>> theirs\n}\n"
+	if got := string(conflictMarkers([]byte(ours), []byte(theirs), "ours", "theirs")); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+)
+
+// Comparator 自定义节点的比较方式，通过 UseComparatorOption 或 UseTypeComparatorOption 注册
+type Comparator interface {
+	// Compare 比较 path 处的 source 和 patch，source 和 patch 都不为 nil。
+	// 返回 true 表示两者相等，不输出差异；返回 false 时，ops 不为空则把 ops 作为这棵子树的差异输出，
+	// 否则使用一个 replace 替换整个节点。ops 中的每一项都应当是一个 RFC 6902 的操作对象
+	Compare(path string, source, patch *decode.JsonNode) (equal bool, ops []*decode.JsonNode)
+}
+
+// ComparatorFunc 把一个判断相等的函数适配为 Comparator，不相等时使用 replace 替换整个节点
+type ComparatorFunc func(path string, source, patch *decode.JsonNode) bool
+
+func (f ComparatorFunc) Compare(path string, source, patch *decode.JsonNode) (bool, []*decode.JsonNode) {
+	return f(path, source, patch), nil
+}
+
+// ValueType 是 JSON 值的类型，用于 UseTypeComparatorOption
+type ValueType int
+
+const (
+	ValueTypeNull ValueType = iota + 1
+	ValueTypeBool
+	ValueTypeNumber
+	ValueTypeString
+	ValueTypeArray
+	ValueTypeObject
+)
+
+// valueTypeOf 返回节点的值类型
+func valueTypeOf(node *decode.JsonNode) ValueType {
+	switch node.Type {
+	case decode.JsonNodeTypeSlice:
+		return ValueTypeArray
+	case decode.JsonNodeTypeObject:
+		return ValueTypeObject
+	}
+	switch node.Value.(type) {
+	case nil:
+		return ValueTypeNull
+	case bool:
+		return ValueTypeBool
+	case string:
+		return ValueTypeString
+	}
+	if _, ok := decode.ToNumber(node.Value); ok {
+		return ValueTypeNumber
+	}
+	return 0
+}
+
+// comparatorOption 保存一个按路径或按类型注册的 Comparator
+type comparatorOption struct {
+	pattern    []string
+	valueType  ValueType
+	comparator Comparator
+}
+
+func (o comparatorOption) apply(cfg *diffConfig) {
+	if o.comparator == nil {
+		return
+	}
+	if o.pattern != nil {
+		cfg.pathComparators = append(cfg.pathComparators, o)
+	} else if o.valueType != 0 {
+		cfg.typeComparators = append(cfg.typeComparators, o)
+	}
+}
+
+// UseComparatorOption 使用 c 比较路径匹配 pattern 的节点，pattern 的格式与 UseIncludeOption 相同，
+// 如 UseComparatorOption("/items/*/url", urlComparator)；pattern 不是合法的 JSON Pointer 时 panic。
+// Comparator 同时作用于 Equal 以及数组最长公共子序列的对齐
+func UseComparatorOption(pattern string, c Comparator) DiffOption {
+	return comparatorOption{pattern: mustParsePattern(pattern), comparator: c}
+}
+
+// UseTypeComparatorOption 使用 c 比较两个值类型都是 t 的节点，
+// 按路径注册的 Comparator 优先于按类型注册的 Comparator，同类注册多个时先注册的优先
+func UseTypeComparatorOption(t ValueType, c Comparator) DiffOption {
+	return comparatorOption{valueType: t, comparator: c}
+}
+
+// comparator 返回比较 path 处的 source 和 patch 时使用的 Comparator
+func (cfg *diffConfig) comparator(path decode.Pointer, source, patch *decode.JsonNode) (Comparator, bool) {
+	if source == nil || patch == nil {
+		return nil, false
+	}
+	if len(cfg.pathComparators) > 0 {
+		tokens := path.Tokens()
+		for _, o := range cfg.pathComparators {
+			if matchGlob(o.pattern, tokens) {
+				return o.comparator, true
+			}
+		}
+	}
+	if len(cfg.typeComparators) > 0 {
+		t := valueTypeOf(source)
+		if t == valueTypeOf(patch) {
+			for _, o := range cfg.typeComparators {
+				if o.valueType == t {
+					return o.comparator, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// diffWithComparator 使用 c 比较 path 处的 source 和 patch 并输出差异
+func diffWithComparator(diffs *diffs, path decode.Pointer, source, patch *decode.JsonNode, c Comparator, cfg *diffConfig) {
+	equal, ops := c.Compare(path.String(), source, patch)
+	if equal {
+		return
+	}
+	if len(ops) == 0 {
+		diffs.add(newDiffNode(DiffTypeReplace, path.String(), patch, "", cfg.flags))
+		return
+	}
+	for _, op := range ops {
+		if op != nil {
+			diffs.add(op)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"strings"
+	"testing"
+)
+
+var caseInsensitive = ComparatorFunc(func(path string, source, patch *decode.JsonNode) bool {
+	a, aOk := source.Value.(string)
+	b, bOk := patch.Value.(string)
+	return aOk && bOk && strings.EqualFold(a, b)
+})
+
+// opaque 把整个子树当作一个值，不相等时输出一个 test 和一个 replace
+type opaque struct{}
+
+func (opaque) Compare(path string, source, patch *decode.JsonNode) (bool, []*decode.JsonNode) {
+	if source.Equal(patch) {
+		return true, nil
+	}
+	return false, []*decode.JsonNode{
+		newDiffNode(DiffTypeTest, path, source, "", 0),
+		newDiffNode(DiffTypeReplace, path, patch, "", 0),
+	}
+}
+
+func TestUseComparatorOption(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		tar     string
+		options []DiffOption
+		want    string
+	}{
+		{
+			"case insensitive enum",
+			`{"level": "INFO", "name": "A"}`,
+			`{"level": "info", "name": "a"}`,
+			[]DiffOption{UseComparatorOption("/level", caseInsensitive)},
+			`[{"op":"replace","path":"/name","value":"a"}]`,
+		},
+		{
+			"type comparator",
+			`{"level": "INFO", "name": "A", "n": 1}`,
+			`{"level": "info", "name": "a", "n": 2}`,
+			[]DiffOption{UseTypeComparatorOption(ValueTypeString, caseInsensitive)},
+			`[{"op":"replace","path":"/n","value":2}]`,
+		},
+		{
+			"comparator in lcs",
+			`{"tags": ["A", "B", "C"]}`,
+			`{"tags": ["x", "a", "b", "c"]}`,
+			[]DiffOption{UseComparatorOption("/tags/*", caseInsensitive)},
+			`[{"op":"add","path":"/tags/0","value":"x"}]`,
+		},
+		{
+			"opaque blob",
+			`{"blob": {"a": 1, "b": 2}}`,
+			`{"blob": {"a": 1, "b": 3}}`,
+			[]DiffOption{UseComparatorOption("/blob", opaque{})},
+			`[{"op":"test","path":"/blob","value":{"a":1,"b":2}},{"op":"replace","path":"/blob","value":{"a":1,"b":3}}]`,
+		},
+		{
+			"replace the whole subtree",
+			`{"blob": {"version": 1, "b": 2}}`,
+			`{"blob": {"version": 2, "b": 3}}`,
+			[]DiffOption{UseComparatorOption("/**/blob", ComparatorFunc(func(_ string, s, p *decode.JsonNode) bool {
+				return s.ChildrenMap["version"].Equal(p.ChildrenMap["version"])
+			}))},
+			`[{"op":"replace","path":"/blob","value":{"version":2,"b":3}}]`,
+		},
+		{
+			"path comparator before type comparator",
+			`{"a": "X", "b": "X"}`,
+			`{"a": "x", "b": "x"}`,
+			[]DiffOption{
+				UseTypeComparatorOption(ValueTypeString, caseInsensitive),
+				UseComparatorOption("/b", ComparatorFunc(func(_ string, s, p *decode.JsonNode) bool {
+					return s.Equal(p)
+				})),
+			},
+			`[{"op":"replace","path":"/b","value":"x"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := AsDiffs([]byte(tt.src), []byte(tt.tar), tt.options...)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if string(diffs) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, diffs)
+			}
+			res, err := MergeDiff([]byte(tt.src), diffs)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			got, _ := decode.Unmarshal(res)
+			want, _ := decode.Unmarshal([]byte(tt.tar))
+			if !Equal(got, want, tt.options...) {
+				t.Errorf("result %s is not equal to %s", res, tt.tar)
+			}
+		})
+	}
+}
+
+func TestEqual_comparator(t *testing.T) {
+	a, _ := decode.Unmarshal([]byte(`{"list": [{"k": "A"}, {"k": "b"}]}`))
+	b, _ := decode.Unmarshal([]byte(`{"list": [{"k": "a"}, {"k": "B"}]}`))
+	if Equal(a, b) {
+		t.Errorf("want not equal without comparator")
+	}
+	if !Equal(a, b, UseComparatorOption("/list/*/k", caseInsensitive)) {
+		t.Errorf("want equal with comparator")
+	}
+	if !Equal(a, b, UseTypeComparatorOption(ValueTypeString, caseInsensitive)) {
+		t.Errorf("want equal with type comparator")
+	}
+	if Equal(a, b, UseTypeComparatorOption(ValueTypeNumber, caseInsensitive)) {
+		t.Errorf("want not equal with number comparator")
+	}
+}
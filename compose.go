@@ -0,0 +1,255 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// ComposePatches 把依次应用于 base 的若干个差异合并为一个等价的差异，
+// 结果是 base 与依次应用所有差异后的文档之间的差异，中间先添加后删除的节点以及同一路径上的多次替换都不会出现在结果中
+func ComposePatches(base []byte, patches ...[]byte) ([]byte, error) {
+	baseNode, err := decode.Unmarshal(base)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to unmarshal base data")
+	}
+	nodes, err := unmarshalPatches(patches)
+	if err != nil {
+		return nil, err
+	}
+	res, err := ComposePatchesNode(baseNode, nodes)
+	if err != nil {
+		return nil, err
+	}
+	return decode.Marshal(res)
+}
+
+// ComposePatchesNode 与 ComposePatches 相同，options 会同时用于应用差异（如 UseLenientMergeOption）
+// 和生成结果（如 UseMoveOption）
+func ComposePatchesNode(base *decode.JsonNode, patches []*decode.JsonNode, options ...DiffOption) (*decode.JsonNode, error) {
+	if base == nil {
+		return nil, errors.New("base is nil")
+	}
+	cur := base
+	for i, patch := range patches {
+		next, err := MergeDiffNode(cur, patch, options...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fail to apply patch %d", i)
+		}
+		cur = next
+	}
+	return GetDiffNode(base, cur, options...), nil
+}
+
+// ComposePatchesSymbolic 在没有源文档的情况下合并若干个差异。
+// 它假设差异是由 GetDiffNode 这类工具生成的：add 只用于添加不存在的节点，replace 和 remove 只用于已存在的节点。
+// 同一路径上的操作会被合并，对已添加或替换的节点的子节点的修改会被直接应用到该节点的值上；
+// 由于数组下标会随着元素的增删变化，路径中出现数组下标（或全部由数字组成的 key）、"-"，
+// 或者出现 move、copy 操作时无法安全地合并，此时返回由 ErrComposeNeedsBase 装饰的 error
+func ComposePatchesSymbolic(patches ...[]byte) ([]byte, error) {
+	nodes, err := unmarshalPatches(patches)
+	if err != nil {
+		return nil, err
+	}
+	res, err := ComposePatchesSymbolicNode(nodes)
+	if err != nil {
+		return nil, err
+	}
+	return decode.Marshal(res)
+}
+
+// ComposePatchesSymbolicNode 与 ComposePatchesSymbolic 相同，但直接使用 JsonNode
+func ComposePatchesSymbolicNode(patches []*decode.JsonNode) (*decode.JsonNode, error) {
+	c := &composer{}
+	for i, patch := range patches {
+		if patch == nil {
+			continue
+		}
+		if patch.Type != decode.JsonNodeTypeSlice {
+			return nil, errors.Wrapf(decode.BadDiffsError, "patch %d must be an array", i)
+		}
+		for j, diff := range patch.Children {
+			if err := c.push(diff); err != nil {
+				return nil, errors.Wrapf(err, "fail to compose diff %d of patch %d", j, i)
+			}
+		}
+	}
+	res := newDiffs()
+	for _, o := range c.ops {
+		t, _ := stringToDiffType(o.op)
+		res.add(newDiffNode(t, o.path.String(), o.value, "", 0))
+	}
+	return res.d, nil
+}
+
+func unmarshalPatches(patches [][]byte) ([]*decode.JsonNode, error) {
+	nodes := make([]*decode.JsonNode, len(patches))
+	for i, patch := range patches {
+		node, err := decode.Unmarshal(patch)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fail to unmarshal patch %d", i)
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+// composedOp 是合并结果中的一个操作
+type composedOp struct {
+	op    string
+	path  decode.Pointer
+	value *decode.JsonNode
+}
+
+// composer 逐个接收操作并维护合并后的结果
+type composer struct {
+	ops []*composedOp
+}
+
+func (c *composer) push(diff *decode.JsonNode) error {
+	if diff == nil || diff.Type != decode.JsonNodeTypeObject {
+		return errors.WithStack(decode.BadDiffsError)
+	}
+	op, err := diffString(diff, "op")
+	if err != nil {
+		return err
+	}
+	path, err := diffString(diff, "path")
+	if err != nil {
+		return err
+	}
+	p, err := decode.ParsePointer(path)
+	if err != nil {
+		return errors.Wrap(decode.ErrInvalidOperation, err.Error())
+	}
+	var value *decode.JsonNode
+	switch op {
+	case "add", "replace", "test":
+		if value, err = diffValue(diff); err != nil {
+			return err
+		}
+		value = cloneNode(value)
+	case "remove":
+	case "move", "copy":
+		return errors.Wrapf(ErrComposeNeedsBase, "%s %s", op, path)
+	default:
+		return errors.Wrapf(decode.ErrInvalidOperation, "unknown op %s", op)
+	}
+
+	// 祖先节点已经被添加或替换时，直接修改它的值
+	for _, o := range c.ops {
+		if o.op != "test" && o.path.IsPrefixOf(p) && o.path.String() != p.String() {
+			return c.fold(o, op, p, value)
+		}
+	}
+	if op == "test" {
+		return c.test(p, value)
+	}
+	if needsBase(p) {
+		return errors.Wrapf(ErrComposeNeedsBase, "%s %s", op, path)
+	}
+
+	// 子节点上的修改都会被覆盖
+	ops := c.ops[:0]
+	var same *composedOp
+	for _, o := range c.ops {
+		if o.op != "test" && p.IsPrefixOf(o.path) {
+			if o.path.String() != p.String() {
+				continue
+			}
+			same = o
+		}
+		ops = append(ops, o)
+	}
+	c.ops = ops
+	if same == nil {
+		c.ops = append(c.ops, &composedOp{op: op, path: p, value: value})
+		return nil
+	}
+	return c.combine(same, op, value)
+}
+
+// combine 合并同一路径上先后的两个操作
+func (c *composer) combine(o *composedOp, op string, value *decode.JsonNode) error {
+	switch {
+	case o.op == "add" && op == "remove":
+		c.drop(o)
+	case o.op == "add":
+		o.value = value
+	case o.op == "replace" && op == "remove":
+		o.op, o.value = "remove", nil
+	case o.op == "replace":
+		o.value = value
+	case o.op == "remove" && op == "add":
+		o.op, o.value = "replace", value
+	default:
+		return errors.Wrapf(decode.ErrPathNotFound, "cannot %s %s after it has been removed", op, o.path)
+	}
+	return nil
+}
+
+func (c *composer) drop(o *composedOp) {
+	for i, v := range c.ops {
+		if v == o {
+			c.ops = append(c.ops[:i], c.ops[i+1:]...)
+			return
+		}
+	}
+}
+
+// fold 把 p 上的操作应用到祖先节点 o 的值上
+func (c *composer) fold(o *composedOp, op string, p decode.Pointer, value *decode.JsonNode) error {
+	if o.op == "remove" {
+		return errors.Wrapf(decode.ErrPathNotFound, "cannot %s %s after %s has been removed", op, p, o.path)
+	}
+	rel := decode.Pointer{}
+	for _, token := range p.Tokens()[len(o.path.Tokens()):] {
+		rel = rel.Append(token)
+	}
+	t, _ := stringToDiffType(op)
+	return mergeOne(o.value, newDiffNode(t, rel.String(), value, "", 0), &diffConfig{})
+}
+
+// test 处理没有被折叠的 test 操作：如果它检查的节点被之前的操作修改过，则无法确定它的结果
+func (c *composer) test(p decode.Pointer, value *decode.JsonNode) error {
+	for _, o := range c.ops {
+		if o.op != "test" && p.IsPrefixOf(o.path) {
+			if o.path.String() == p.String() && o.op != "remove" {
+				if !o.value.Equal(value) {
+					return errors.Wrapf(decode.ErrTestFailed, "test %s", p)
+				}
+				return nil
+			}
+			return errors.Wrapf(ErrComposeNeedsBase, "test %s after %s %s", p, o.op, o.path)
+		}
+	}
+	c.ops = append(c.ops, &composedOp{op: "test", path: p, value: value})
+	return nil
+}
+
+// needsBase 判断 p 中是否有可能是数组下标的 token
+func needsBase(p decode.Pointer) bool {
+	for _, token := range p.Tokens() {
+		if token == "-" || token != "" && strings.Trim(token, "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
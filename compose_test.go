@@ -0,0 +1,176 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestComposePatches(t *testing.T) {
+	base := `{"a": 1, "b": [1, 2, 3], "c": {"d": "x"}}`
+	patches := []string{
+		`[{"op": "replace", "path": "/a", "value": 2}, {"op": "add", "path": "/tmp", "value": {"x": 1}}]`,
+		`[{"op": "replace", "path": "/a", "value": 3}, {"op": "add", "path": "/b/0", "value": 0}, {"op": "add", "path": "/tmp/y", "value": 2}]`,
+		`[{"op": "remove", "path": "/tmp"}, {"op": "remove", "path": "/b/1"}, {"op": "replace", "path": "/c/d", "value": "x"}]`,
+	}
+	args := make([][]byte, len(patches))
+	for i, p := range patches {
+		args[i] = []byte(p)
+	}
+	got, err := ComposePatches([]byte(base), args...)
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	want := `[{"op":"replace","path":"/a","value":3},{"op":"replace","path":"/b/0","value":0}]`
+	if string(got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	_, err = ComposePatches([]byte(base), []byte(`[{"op": "remove", "path": "/x"}]`))
+	var pe *PatchError
+	if !errors.As(err, &pe) || !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("want a PatchError, got %v", err)
+	}
+}
+
+func TestComposePatchesSymbolic(t *testing.T) {
+	tests := []struct {
+		name    string
+		patches []string
+		want    string
+		wantErr error
+	}{
+		{
+			"add then remove",
+			[]string{`[{"op": "add", "path": "/a", "value": 1}]`, `[{"op": "remove", "path": "/a"}]`},
+			`[]`, nil,
+		},
+		{
+			"repeated replace",
+			[]string{
+				`[{"op": "replace", "path": "/a", "value": 1}, {"op": "add", "path": "/b", "value": 1}]`,
+				`[{"op": "replace", "path": "/a", "value": 2}]`,
+				`[{"op": "replace", "path": "/a", "value": 3}, {"op": "replace", "path": "/b", "value": 2}]`,
+			},
+			`[{"op":"replace","path":"/a","value":3},{"op":"add","path":"/b","value":2}]`, nil,
+		},
+		{
+			"remove then add",
+			[]string{`[{"op": "remove", "path": "/a"}]`, `[{"op": "add", "path": "/a", "value": 2}]`},
+			`[{"op":"replace","path":"/a","value":2}]`, nil,
+		},
+		{
+			"replace then remove",
+			[]string{`[{"op": "replace", "path": "/a", "value": 2}]`, `[{"op": "remove", "path": "/a"}]`},
+			`[{"op":"remove","path":"/a"}]`, nil,
+		},
+		{
+			"fold into an added value",
+			[]string{
+				`[{"op": "add", "path": "/a", "value": {"b": [1, 2]}}]`,
+				`[{"op": "add", "path": "/a/b/-", "value": 3}, {"op": "remove", "path": "/a/b/0"}, {"op": "test", "path": "/a/b/0", "value": 2}]`,
+			},
+			`[{"op":"add","path":"/a","value":{"b":[2,3]}}]`, nil,
+		},
+		{
+			"parent overrides children",
+			[]string{
+				`[{"op": "replace", "path": "/a/b", "value": 1}, {"op": "add", "path": "/a/c", "value": 1}, {"op": "test", "path": "/x", "value": 1}]`,
+				`[{"op": "remove", "path": "/a"}]`,
+			},
+			`[{"op":"test","path":"/x","value":1},{"op":"remove","path":"/a"}]`, nil,
+		},
+		{
+			"array index",
+			[]string{`[{"op": "remove", "path": "/a/0"}]`},
+			``, ErrComposeNeedsBase,
+		},
+		{
+			"move",
+			[]string{`[{"op": "move", "path": "/a", "from": "/b"}]`},
+			``, ErrComposeNeedsBase,
+		},
+		{
+			"test after a change of a child",
+			[]string{`[{"op": "replace", "path": "/a/b", "value": 1}]`, `[{"op": "test", "path": "/a", "value": {"b": 1}}]`},
+			``, ErrComposeNeedsBase,
+		},
+		{
+			"test fails",
+			[]string{`[{"op": "replace", "path": "/a", "value": 1}]`, `[{"op": "test", "path": "/a", "value": 2}]`},
+			``, ErrTestFailed,
+		},
+		{
+			"change after remove",
+			[]string{`[{"op": "remove", "path": "/a"}]`, `[{"op": "replace", "path": "/a/b", "value": 2}]`},
+			``, ErrPathNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := make([][]byte, len(tt.patches))
+			for i, p := range tt.patches {
+				args[i] = []byte(p)
+			}
+			got, err := ComposePatchesSymbolic(args...)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("want %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestComposePatchesSymbolic_equivalent(t *testing.T) {
+	base := `{"a": 1, "b": {"c": 1, "d": 2}, "x": 1}`
+	patches := [][]byte{
+		[]byte(`[{"op": "replace", "path": "/a", "value": 2}, {"op": "add", "path": "/e", "value": {"f": 1}}, {"op": "test", "path": "/x", "value": 1}]`),
+		[]byte(`[{"op": "remove", "path": "/b/c"}, {"op": "add", "path": "/e/g", "value": [1]}, {"op": "replace", "path": "/a", "value": 3}]`),
+		[]byte(`[{"op": "replace", "path": "/b/d", "value": 3}, {"op": "remove", "path": "/e/f"}, {"op": "add", "path": "/b/c", "value": 0}]`),
+	}
+	want := []byte(base)
+	for _, p := range patches {
+		var err error
+		if want, err = MergeDiff(want, p); err != nil {
+			t.Fatalf("got an error: %v", err)
+		}
+	}
+	composed, err := ComposePatchesSymbolic(patches...)
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	got, err := MergeDiff([]byte(base), composed)
+	if err != nil {
+		t.Fatalf("fail to apply %s: %v", composed, err)
+	}
+	a, _ := decode.Unmarshal(got)
+	b, _ := decode.Unmarshal(want)
+	if !a.Equal(b) {
+		t.Errorf("want %s, got %s (%s)", want, got, composed)
+	}
+}
@@ -18,25 +18,119 @@
 package decode
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 // BadDiffsError 在输入不合法的 diffs 串时被返回
 var BadDiffsError = errors.New("diffs format error")
 
+// BadPointerError 在输入不合法的 JSON Pointer 时被返回
+var BadPointerError = errors.New("json pointer format error")
+
+func pointerError(pointer, msg string) error {
+	return errors.Wrapf(BadPointerError, "%q %s", pointer, msg)
+}
+
+// ErrPathNotFound 在 JSON Pointer 指向的节点或它的父节点不存在时被返回
+var ErrPathNotFound = errors.New("path not found")
+
+// ErrIndexOutOfRange 在数组下标超出范围时被返回
+var ErrIndexOutOfRange = errors.New("index out of range")
+
+// ErrTestFailed 在 test 操作的值不相等时被返回
+var ErrTestFailed = errors.New("test failed")
+
+// ErrInvalidOperation 在操作本身不合法时被返回，如 path 不是合法的 JSON Pointer、
+// 将节点移动到它自己的子节点中等
+var ErrInvalidOperation = errors.New("invalid operation")
+
 type JsonNodeError struct {
-	Op string
+	Op     string
+	Reason error // 失败的原因，是 ErrPathNotFound 等错误之一，可以使用 errors.Is 判断
 }
 
 func (e *JsonNodeError) Error() string {
 	return fmt.Sprintf("fail to %s", e.Op)
 }
 
+func (e *JsonNodeError) Unwrap() error {
+	return e.Reason
+}
+
 func GetJsonNodeError(op, msg string) error {
 	return errors.Wrap(&JsonNodeError{Op: op}, msg)
 }
 
+func jsonNodeError(op string, reason error, msg string) error {
+	return errors.Wrap(&JsonNodeError{Op: op, Reason: reason}, msg)
+}
+
 func WrapJsonNodeError(op string, err error) error {
 	return errors.Wrap(err, fmt.Sprintf("fail to %s", op))
 }
+
+// snippetRadius 是 SyntaxError.Snippet 在出错位置前后各截取的最大字节数
+const snippetRadius = 20
+
+// SyntaxError 描述 json 输入中的语法错误，由 Unmarshal 返回，可以使用 errors.As 获取
+type SyntaxError struct {
+	Offset   int64  // 出错位置相对于输入起始处的字节偏移，从 0 开始
+	Line     int    // 出错位置所在的行，从 1 开始
+	Column   int    // 出错位置所在的列，以字节计，从 1 开始
+	Token    string // 实际遇到的 token
+	Expected string // 期望的 token 类别
+	Snippet  string // 出错位置附近（同一行内）的输入片段
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d (offset %d): unexpected %s, expected %s near %s",
+		e.Line, e.Column, e.Offset, e.Token, e.Expected, strconv.Quote(e.Snippet))
+}
+
+// snippet 截取 line 中 off 前后的片段，不会跨越换行，也不会截断多字节字符
+func snippet(line []byte, off int) string {
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	start, end := off-snippetRadius, off+snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	if end > len(line) {
+		end = len(line)
+	}
+	if start > end {
+		start = end
+	}
+	for start > 0 && !utf8.RuneStart(line[start]) {
+		start--
+	}
+	for end < len(line) && !utf8.RuneStart(line[end]) {
+		end++
+	}
+	return strings.TrimRight(string(line[start:end]), "\r")
+}
+
+func snippetOf(s string) string {
+	if len(s) <= snippetRadius {
+		return s
+	}
+	end := snippetRadius
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end] + "..."
+}
+
+// describeByte 返回用于错误信息的字符描述
+func describeByte(b byte) string {
+	if b < 0x20 || b >= utf8.RuneSelf {
+		return fmt.Sprintf("byte 0x%02x", b)
+	}
+	return "'" + string(b) + "'"
+}
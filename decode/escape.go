@@ -0,0 +1,141 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package decode
+
+import (
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// unescape 解析 data 开头的一个转义序列（data[0] 为 '\\'），将还原后的字符写入 build，
+// 返回该转义序列占用的字节数，转义序列不合法时第二个返回值为 false。
+// \uXXXX 形式的 UTF-16 代理对会被合并为一个字符，孤立的代理项会被替换为 U+FFFD
+func unescape(build *builder, data []byte) (int, bool) {
+	if len(data) < 2 {
+		return 0, false
+	}
+	switch data[1] {
+	case '"', '\\', '/':
+		_ = build.WriteByte(data[1])
+	case 'b':
+		_ = build.WriteByte('\b')
+	case 'f':
+		_ = build.WriteByte('\f')
+	case 'n':
+		_ = build.WriteByte('\n')
+	case 'r':
+		_ = build.WriteByte('\r')
+	case 't':
+		_ = build.WriteByte('\t')
+	case 'u':
+		r, ok := hex4(data[2:])
+		if !ok {
+			return 0, false
+		}
+		size := 6
+		if r >= 0xD800 && r < 0xDC00 {
+			// 高位代理项，尝试与紧随其后的低位代理项组成一个字符
+			if len(data) >= 12 && data[6] == '\\' && data[7] == 'u' {
+				if r2, ok := hex4(data[8:]); ok && r2 >= 0xDC00 && r2 < 0xE000 {
+					r = (r-0xD800)<<10 | (r2 - 0xDC00) + 0x10000
+					size = 12
+				}
+			}
+		}
+		if r >= 0xD800 && r < 0xE000 {
+			r = utf8.RuneError
+		}
+		var buf [utf8.UTFMax]byte
+		n := utf8.EncodeRune(buf[:], r)
+		build.Write(buf[:n])
+		return size, true
+	default:
+		return 0, false
+	}
+	return 2, true
+}
+
+func hex4(data []byte) (rune, bool) {
+	if len(data) < 4 {
+		return 0, false
+	}
+	var r rune
+	for _, c := range data[:4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c = c - '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
+
+// writeQuotedString 将 s 按 RFC 8259 的要求转义后连同两侧引号写入 build。
+// '"'、'\\' 及控制字符会被转义，非法的 UTF-8 字节会被替换为 U+FFFD
+func writeQuotedString(build *builder, s string) {
+	_ = build.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			build.Write([]byte(s[start:i]))
+			_ = build.WriteByte('\\')
+			switch c {
+			case '"', '\\':
+				_ = build.WriteByte(c)
+			case '\b':
+				_ = build.WriteByte('b')
+			case '\f':
+				_ = build.WriteByte('f')
+			case '\n':
+				_ = build.WriteByte('n')
+			case '\r':
+				_ = build.WriteByte('r')
+			case '\t':
+				_ = build.WriteByte('t')
+			default:
+				build.Write([]byte{'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF]})
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			build.Write([]byte(s[start:i]))
+			build.Write([]byte("\ufffd"))
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	build.Write([]byte(s[start:]))
+	_ = build.WriteByte('"')
+}
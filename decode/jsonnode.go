@@ -18,54 +18,27 @@
 package decode
 
 import (
-	"bytes"
 	"fmt"
-	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 )
 
-var keyReplaceRegexp = regexp.MustCompile(`~0*1`)
-
-// KeyReplace 转义 key 中的特殊字符
-// "/"    会被替换成 "~1"
-// "~1"   会被替换成 "~01"
-// "~01"  会被替换为 "~001"
-// "~001" 会被替换为 "~0001"
-// 依此类推
+// KeyReplace 按照 RFC 6901 转义 key 中的特殊字符，使其可以作为 JSON Pointer 的一个 token
+// "~" 会被替换成 "~0"
+// "/" 会被替换成 "~1"
 func KeyReplace(key string) string {
-	resList := keyReplaceRegexp.FindAllStringIndex(key, -1)
-	buff := bytes.NewBufferString("")
-	pre := 0
-	for _, res := range resList {
-		buff.WriteString(key[pre:res[0]])
-		buff.WriteRune('~')
-		for i := 1; i < res[1]-res[0]; i++ {
-			buff.WriteRune('0')
-		}
-		buff.WriteRune('1')
-		pre = res[1]
+	if !strings.ContainsAny(key, "~/") {
+		return key
 	}
-	buff.WriteString(key[pre:])
-	return strings.ReplaceAll(buff.String(), "/", "~1")
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
 }
 
+// KeyRestore 是 KeyReplace 的逆操作，先将 "~1" 还原为 "/"，再将 "~0" 还原为 "~"
 func KeyRestore(key string) string {
-	key = strings.ReplaceAll(key, "~1", "/")
-	resList := keyReplaceRegexp.FindAllStringIndex(key, -1)
-	buff := bytes.NewBufferString("")
-	pre := 0
-	for _, res := range resList {
-		buff.WriteString(key[pre:res[0]])
-		buff.WriteRune('~')
-		for i := 3; i < res[1]-res[0]; i++ {
-			buff.WriteRune('0')
-		}
-		buff.WriteRune('1')
-		pre = res[1]
+	if !strings.Contains(key, "~") {
+		return key
 	}
-	buff.WriteString(key[pre:])
-	return buff.String()
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~1", "/"), "~0", "~")
 }
 
 type JsonNodeType uint8
@@ -119,6 +92,7 @@ type JsonNode struct {
 	Value         interface{}          `json:"value"`        // 保存 JsonNodeTypeValue 类型对象的值
 	Children      []*JsonNode          `json:"children"`     // 保存 JsonNodeTypeSlice 类型对象的值
 	ChildrenMap   map[string]*JsonNode `json:"children_map"` // 保存 JsonNodeTypeObject 类型对象的值
+	Keys          []string             `json:"keys"`         // 保存 JsonNodeTypeObject 类型对象 key 的插入顺序
 	Level         int64                `json:"level"`        // 该 node 所处的层级
 	originalValue []byte               // 保存反序列化时最原始的值，避免序列化动态类型转换
 }
@@ -132,11 +106,19 @@ func newOriginalValueNode(ov []byte, value interface{}, level int) *JsonNode {
 	}
 }
 
+// NewObjectNode 创建一个 JsonNodeTypeObject 类型的节点，
+// 由于 map 是无序的，childrenMap 中已有的 key 会按字典序排列
 func NewObjectNode(key string, childrenMap map[string]*JsonNode, level int) *JsonNode {
+	keys := make([]string, 0, len(childrenMap))
+	for k := range childrenMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 	return &JsonNode{
 		Type:        JsonNodeTypeObject,
 		Key:         key,
 		ChildrenMap: childrenMap,
+		Keys:        keys,
 		Level:       int64(level),
 	}
 }
@@ -158,8 +140,9 @@ func NewValueNode(value interface{}, level int) *JsonNode {
 }
 
 // ADD 为当前的 JsonNode 节点添加子对象。
-// 当当前节点为 JsonNodeTypeObject 类型时，key 必须是 string 类型；
-// 当当前节点为 JsonNodeTypeSlice 类型时，key 表示新加入节点的位置下标，必须能转换为 int 类型；
+// 当当前节点为 JsonNodeTypeObject 类型时，key 必须是 string 类型，已存在的 key 会被覆盖；
+// 当当前节点为 JsonNodeTypeSlice 类型时，key 表示新加入节点的位置下标，必须能转换为 int 类型，
+// 且不能超过当前 Children 的长度，"-" 表示追加到末尾；
 // 不能对 JsonNodeTypeValue 类型的节点执行 ADD 操作；
 // 不符合上述要求该方法返回一个由 BadDiffsError 装饰的 error
 func (jn *JsonNode) ADD(key interface{}, value *JsonNode) error {
@@ -167,40 +150,36 @@ func (jn *JsonNode) ADD(key interface{}, value *JsonNode) error {
 	case JsonNodeTypeObject:
 		k, ok := key.(string)
 		if !ok {
-			return GetJsonNodeError("add", keyMastString(key))
+			return jsonNodeError("add", ErrInvalidOperation, keyMastString(key))
+		}
+		if _, ok := jn.ChildrenMap[k]; !ok {
+			jn.Keys = append(jn.Keys, k)
 		}
 		jn.ChildrenMap[k] = value
 	case JsonNodeTypeSlice:
-		k := 0
-		switch key.(type) {
-		case string:
-			var err error
-			k, err = strconv.Atoi(key.(string))
-			if err != nil {
-				return GetJsonNodeError("add", keyMustCanBeConvertibleToInt(key))
-			}
-		case int:
-			k = key.(int)
-		default:
-			return GetJsonNodeError("add", keyMustCanBeConvertibleToInt(key))
-		}
 		size := len(jn.Children)
-		if k > size || k < 0 {
-			// TODO: 当传入的 index 大于当前 Children 长度时，可以配置处理方式
+		if key == "-" {
 			jn.Children = append(jn.Children, value)
-		} else {
-			n := make([]*JsonNode, size+1)
-			for i := 0; i < k; i++ {
-				n[i] = jn.Children[i]
-			}
-			n[k] = value
-			for i := k + 1; i < size+1; i++ {
-				n[i] = jn.Children[i-1]
-			}
-			jn.Children = n
+			return nil
 		}
+		k, err := sliceIndex("add", key)
+		if err != nil {
+			return err
+		}
+		if k > size || k < 0 {
+			return jsonNodeError("add", ErrIndexOutOfRange, fmt.Sprintf("index(%d) out of range (%d)", k, size))
+		}
+		n := make([]*JsonNode, size+1)
+		for i := 0; i < k; i++ {
+			n[i] = jn.Children[i]
+		}
+		n[k] = value
+		for i := k + 1; i < size+1; i++ {
+			n[i] = jn.Children[i-1]
+		}
+		jn.Children = n
 	default:
-		return GetJsonNodeError("add",
+		return jsonNodeError("add", ErrPathNotFound,
 			"cannot add an object to a node of type JsonNodeTypeValue")
 	}
 	return nil
@@ -218,16 +197,56 @@ func (jn *JsonNode) Append(v *JsonNode) error {
 }
 
 // AddPath 为 node 的 path 路径处的对象添加一个子节点
-// path 路径表示的是子节点加入后的路径, 以 "/" 开头
+// path 路径表示的是子节点加入后的路径, 以 "/" 开头，
+// path 为 "" 时使用 value 替换整个 node
 func AddPath(node *JsonNode, path string, value *JsonNode) error {
-	childKey, f, err := splitKey(node, path)
+	if value == nil {
+		return jsonNodeError("add", ErrInvalidOperation, "value is nil")
+	}
+	if path == "" {
+		*node = *value
+		return nil
+	}
+	childKey, f, err := splitKey("add", node, path)
 	if err != nil {
-		return GetJsonNodeError("add",
-			fmt.Sprintf("path (%s) is not compliant", path))
+		return err
 	}
 	return f.ADD(childKey, value)
 }
 
+// ObjectKeys 按插入顺序返回 JsonNodeTypeObject 类型节点的所有 key。
+// 直接修改 ChildrenMap 而没有同步 Keys 时，Keys 中不存在的 key 会按字典序排在最后
+func (jn *JsonNode) ObjectKeys() []string {
+	keys := make([]string, 0, len(jn.ChildrenMap))
+	seen := make(map[string]bool, len(jn.ChildrenMap))
+	for _, k := range jn.Keys {
+		if _, ok := jn.ChildrenMap[k]; ok && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == len(jn.ChildrenMap) {
+		return keys
+	}
+	rest := make([]string, 0, len(jn.ChildrenMap)-len(keys))
+	for k := range jn.ChildrenMap {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+func (jn *JsonNode) removeKey(key string) {
+	for i, k := range jn.Keys {
+		if k == key {
+			jn.Keys = append(jn.Keys[:i:i], jn.Keys[i+1:]...)
+			return
+		}
+	}
+}
+
 func (jn *JsonNode) String() string {
 	return jn.Key
 }
@@ -235,7 +254,7 @@ func (jn *JsonNode) String() string {
 // Equal 比较当前节点和 patch 是否相等
 // 对于两个 JsonNodeTypeObject 类型，不关心顺序，每个 key 对应的 value 都相等才认为相等；
 // 对于两个 JsonNodeTypeSlice 类型，Children 中每个位置对应的元素都相等才认为相等；
-// 对于两个 JsonNodeTypeValue 类型，Value 相等即认为相等。
+// 对于两个 JsonNodeTypeValue 类型，Value 相等即认为相等，数值按十进制精确比较，如 1 和 1.0 相等。
 func (jn *JsonNode) Equal(patch *JsonNode) bool {
 	if jn == nil && patch == nil {
 		return true
@@ -271,7 +290,7 @@ func (jn *JsonNode) Equal(patch *JsonNode) bool {
 			}
 		}
 	case JsonNodeTypeValue:
-		return jn.Value == patch.Value
+		return valueEqual(jn.Value, patch.Value)
 	}
 	return true
 }
@@ -279,7 +298,6 @@ func (jn *JsonNode) Equal(patch *JsonNode) bool {
 func (jn *JsonNode) find(paths []string) (*JsonNode, bool) {
 	root := jn
 	for _, key := range paths {
-		key = KeyRestore(key)
 		switch root.Type {
 		case JsonNodeTypeObject:
 			r, ok := root.ChildrenMap[key]
@@ -288,16 +306,13 @@ func (jn *JsonNode) find(paths []string) (*JsonNode, bool) {
 			}
 			root = r
 		case JsonNodeTypeSlice:
-			n, err := strconv.Atoi(key)
-			if err != nil {
-				return nil, false
-			}
-			if n > len(root.Children)-1 {
+			n, ok := arrayIndex(key)
+			if !ok || n > len(root.Children)-1 {
 				return nil, false
 			}
 			root = root.Children[n]
-		case JsonNodeTypeValue:
-			return root, true
+		default:
+			return nil, false
 		}
 	}
 	return root, true
@@ -326,38 +341,35 @@ func (jn *JsonNode) find(paths []string) (*JsonNode, bool) {
 //      "name": "梭罗",
 //      "country": "US",
 //   }
+// path 必须是合法的 JSON Pointer，"" 表示当前节点本身
 func (jn *JsonNode) Find(path string) (*JsonNode, bool) {
-	link := strings.Split(path, "/")
-	if len(link) <= 1 {
-		return jn, true
+	p, err := ParsePointer(path)
+	if err != nil {
+		return nil, false
 	}
-	return jn.find(link[1:])
+	return jn.FindPointer(p)
+}
+
+// FindPointer 根据 JSON Pointer 返回对应的 node 节点
+func (jn *JsonNode) FindPointer(p Pointer) (*JsonNode, bool) {
+	return jn.find(p.tokens)
 }
 
 // Replace 使用 value 替换当前节点的 key 的值, 并返回旧值。
-// 当当前节点为 JsonNodeTypeObject 类型时，key 必须是 string 类型；
+// 当当前节点为 JsonNodeTypeObject 类型时，key 必须是 string 类型且必须已经存在；
 // 当当前节点为 JsonNodeTypeSlice 类型时，key 表示新加入节点的位置下标，必须能转换为 int 类型；
 // 不符合上述要求该方法返回一个由 BadDiffsError 装饰的 error
 func (jn *JsonNode) Replace(key interface{}, value *JsonNode) (*JsonNode, error) {
 	var old *JsonNode
 	switch jn.Type {
 	case JsonNodeTypeSlice:
-		index := 0
-		var err error
-		switch key.(type) {
-		case string:
-			index, err = strconv.Atoi(key.(string))
-			if err != nil {
-				return nil, GetJsonNodeError("replace", keyMustCanBeConvertibleToInt(key))
-			}
-		case int:
-			index = key.(int)
-		default:
-			return nil, GetJsonNodeError("replace", keyMustCanBeConvertibleToInt(key))
+		index, err := sliceIndex("replace", key)
+		if err != nil {
+			return nil, err
 		}
 		size := len(jn.Children)
 		if index > size-1 || index < 0 {
-			return nil, GetJsonNodeError("replace",
+			return nil, jsonNodeError("replace", ErrIndexOutOfRange,
 				fmt.Sprintf("index(%d) out of range (%d)", index, size))
 		}
 		old = jn.Children[index]
@@ -365,9 +377,12 @@ func (jn *JsonNode) Replace(key interface{}, value *JsonNode) (*JsonNode, error)
 	case JsonNodeTypeObject:
 		key, ok := key.(string)
 		if !ok {
-			return nil, GetJsonNodeError("replace", keyMastString(key))
+			return nil, jsonNodeError("replace", ErrInvalidOperation, keyMastString(key))
+		}
+		old, ok = jn.ChildrenMap[key]
+		if !ok {
+			return nil, jsonNodeError("replace", ErrPathNotFound, fmt.Sprintf("key(%s) does not exist", key))
 		}
-		old = jn.ChildrenMap[key]
 		jn.ChildrenMap[key] = value
 	case JsonNodeTypeValue:
 		old = jn
@@ -376,12 +391,21 @@ func (jn *JsonNode) Replace(key interface{}, value *JsonNode) (*JsonNode, error)
 	return old, nil
 }
 
-// ReplacePath 替换 node 中 path 处的对象为 value, 并返回旧值
+// ReplacePath 替换 node 中 path 处的对象为 value, 并返回旧值，
+// path 为 "" 时使用 value 替换整个 node
 func ReplacePath(node *JsonNode, path string, value *JsonNode) (*JsonNode, error) {
+	if value == nil {
+		return nil, jsonNodeError("replace", ErrInvalidOperation, "value is nil")
+	}
+	if path == "" {
+		old := *node
+		*node = *value
+		return &old, nil
+	}
 	// 游标移动到 path 对应的位置
-	childKey, f, err := splitKey(node, path)
+	childKey, f, err := splitKey("replace", node, path)
 	if err != nil {
-		return nil, GetJsonNodeError("replace", fmt.Sprintf("path (%s) is not compliant", path))
+		return nil, err
 	}
 	return f.Replace(childKey, value)
 }
@@ -393,34 +417,26 @@ func (jn *JsonNode) Remove(key interface{}) (*JsonNode, error) {
 	var old *JsonNode
 	switch jn.Type {
 	case JsonNodeTypeValue:
-		return nil, GetJsonNodeError("remove", "unable to execute remove on JsonNodeTypeValue")
+		return nil, jsonNodeError("remove", ErrPathNotFound, "unable to execute remove on JsonNodeTypeValue")
 	case JsonNodeTypeObject:
 		key, ok := key.(string)
 		if !ok {
-			return nil, GetJsonNodeError("remove", keyMastString(key))
+			return nil, jsonNodeError("remove", ErrInvalidOperation, keyMastString(key))
 		}
 		if _, ok := jn.ChildrenMap[key]; !ok {
-			return nil, GetJsonNodeError("remove", fmt.Sprintf("key(%s) does not exist", key))
+			return nil, jsonNodeError("remove", ErrPathNotFound, fmt.Sprintf("key(%s) does not exist", key))
 		}
 		old = jn.ChildrenMap[key]
 		delete(jn.ChildrenMap, key)
+		jn.removeKey(key)
 	case JsonNodeTypeSlice:
-		index := 0
-		var err error
-		switch key.(type) {
-		case string:
-			index, err = strconv.Atoi(key.(string))
-			if err != nil {
-				return nil, GetJsonNodeError("remove", keyMustCanBeConvertibleToInt(key))
-			}
-		case int:
-			index = key.(int)
-		default:
-			return nil, GetJsonNodeError("remove", keyMustCanBeConvertibleToInt(key))
+		index, err := sliceIndex("remove", key)
+		if err != nil {
+			return nil, err
 		}
 		size := len(jn.Children)
 		if index > size-1 || index < 0 {
-			return nil, GetJsonNodeError("remove", fmt.Sprintf("index(%d) out of range (%d)", index, size))
+			return nil, jsonNodeError("remove", ErrIndexOutOfRange, fmt.Sprintf("index(%d) out of range (%d)", index, size))
 		}
 		old = jn.Children[index]
 		n := make([]*JsonNode, size-1)
@@ -437,45 +453,57 @@ func (jn *JsonNode) Remove(key interface{}) (*JsonNode, error) {
 
 // RemovePath 删除并返回 node 中根据 path 找到的节点。
 func RemovePath(node *JsonNode, path string) (*JsonNode, error) {
-	childKey, f, err := splitKey(node, path)
+	childKey, f, err := splitKey("remove", node, path)
 	if err != nil {
-		return nil, GetJsonNodeError("remove", fmt.Sprintf("path (%s) is not compliant", path))
+		return nil, err
 	}
 	return f.Remove(childKey)
 }
 
-// MovePath 将 node 中 from 处的节点移动到 path 处
+// MovePath 将 node 中 from 处的节点移动到 path 处，
+// 等价于先删除 from 处的节点，再将它添加到 path 处，from 不能是 path 的祖先节点
 func MovePath(node *JsonNode, from, path string) (*JsonNode, error) {
-	fromNode, ok := node.Find(from)
-	if !ok {
-		return nil, GetJsonNodeError("move", fmt.Sprintf("from path(%s) not find", from))
+	fromPointer, err := ParsePointer(from)
+	if err != nil {
+		return nil, jsonNodeError("move", ErrInvalidOperation, err.Error())
 	}
-	_, ok = node.Find(path)
-	if ok {
-		_, err := ReplacePath(node, path, fromNode)
-		if err != nil {
-			return nil, WrapJsonNodeError("move", err)
+	pathPointer, err := ParsePointer(path)
+	if err != nil {
+		return nil, jsonNodeError("move", ErrInvalidOperation, err.Error())
+	}
+	if fromPointer.IsPrefixOf(pathPointer) {
+		fromNode, ok := node.FindPointer(fromPointer)
+		if !ok {
+			return nil, jsonNodeError("move", ErrPathNotFound, fmt.Sprintf("from path(%s) not find", from))
 		}
-	} else {
-		err := AddPath(node, path, fromNode)
-		if err != nil {
-			return nil, WrapJsonNodeError("move", err)
+		if from == path {
+			return fromNode, nil
 		}
+		return nil, jsonNodeError("move", ErrInvalidOperation,
+			fmt.Sprintf("from path(%s) is an ancestor of path(%s)", from, path))
 	}
 	old, err := RemovePath(node, from)
 	if err != nil {
 		return nil, WrapJsonNodeError("move", err)
 	}
+	err = AddPath(node, path, old)
+	if err != nil {
+		return nil, WrapJsonNodeError("move", err)
+	}
 	return old, nil
 }
 
-// CopyPath 将 node from 处的节点复制到 path 处
+// CopyPath 将 node from 处节点的副本添加到 path 处
 func CopyPath(node *JsonNode, from, path string) error {
-	fromNode, ok := node.Find(from)
+	p, err := ParsePointer(from)
+	if err != nil {
+		return jsonNodeError("copy", ErrInvalidOperation, err.Error())
+	}
+	fromNode, ok := node.FindPointer(p)
 	if !ok {
-		return GetJsonNodeError("copy", fmt.Sprintf("from path(%s) not find", from))
+		return jsonNodeError("copy", ErrPathNotFound, fmt.Sprintf("from path(%s) not find", from))
 	}
-	err := AddPath(node, path, fromNode)
+	err = AddPath(node, path, fromNode.clone())
 	if err != nil {
 		return WrapJsonNodeError("copy", err)
 	}
@@ -483,54 +511,125 @@ func CopyPath(node *JsonNode, from, path string) error {
 }
 
 func ATestPath(srcNode *JsonNode, path string, value *JsonNode) error {
-	f, ok := srcNode.Find(path)
+	if value == nil {
+		return jsonNodeError("test", ErrInvalidOperation, "value is nil")
+	}
+	p, err := ParsePointer(path)
+	if err != nil {
+		return jsonNodeError("test", ErrInvalidOperation, err.Error())
+	}
+	f, ok := srcNode.FindPointer(p)
 	if !ok {
-		return GetJsonNodeError("test", fmt.Sprintf("%s not find", path))
+		return jsonNodeError("test", ErrPathNotFound, fmt.Sprintf("%s not find", path))
 	}
 	if f.Type != value.Type {
-		return GetJsonNodeError("test",
+		return jsonNodeError("test", ErrTestFailed,
 			fmt.Sprintf("types are not equal, one is %s, another is %s",
 				f.Type.String(), value.Type.String()))
 	}
 	switch value.Type {
 	case JsonNodeTypeValue:
 		// [{"op": "test", "path": "a/b/c", "value":"123"}]
-		if f.Value != value.Value {
-			return GetJsonNodeError("test", valueAreNotEqual(f.Value, value.Value))
+		if !valueEqual(f.Value, value.Value) {
+			return jsonNodeError("test", ErrTestFailed, valueAreNotEqual(f.Value, value.Value))
 		}
 	case JsonNodeTypeSlice:
 		// [{"op": "test", "path": "a/b/c", "value":[123, 456]}]
 		if len(f.Children) != len(value.Children) {
-			return GetJsonNodeError("test", valueAreNotEqual(f.Children, value.Children))
+			return jsonNodeError("test", ErrTestFailed, valueAreNotEqual(f.Children, value.Children))
 		}
 		for i, v := range value.Children {
 			if !v.Equal(f.Children[i]) {
-				return GetJsonNodeError("test", valueAreNotEqual(v, f.Children[i]))
+				return jsonNodeError("test", ErrTestFailed, valueAreNotEqual(v, f.Children[i]))
 			}
 		}
 	case JsonNodeTypeObject:
 		if len(f.ChildrenMap) != len(value.ChildrenMap) {
-			return GetJsonNodeError("test", valueAreNotEqual(f.ChildrenMap, value.ChildrenMap))
+			return jsonNodeError("test", ErrTestFailed, valueAreNotEqual(f.ChildrenMap, value.ChildrenMap))
 		}
 		for k, v := range value.ChildrenMap {
 			if !v.Equal(f.ChildrenMap[k]) {
-				return GetJsonNodeError("test", valueAreNotEqual(v, f.ChildrenMap[k]))
+				return jsonNodeError("test", ErrTestFailed, valueAreNotEqual(v, f.ChildrenMap[k]))
 			}
 		}
 	}
 	return nil
 }
 
-func splitKey(node *JsonNode, path string) (string, *JsonNode, error) {
-	paths := strings.Split(path, "/")[1:]
-	size := len(paths)
-	childKey := paths[size-1]
-	paths = paths[:size-1]
-	f, ok := node.find(paths)
+// ATestPathWith 与 ATestPath 相同，但使用 equal 判断 path 处的节点与 value 是否相等，
+// equal 的第一个参数是 path 解析后的 Pointer，equal 为 nil 时等同于 ATestPath
+func ATestPathWith(srcNode *JsonNode, path string, value *JsonNode, equal func(p Pointer, a, b *JsonNode) bool) error {
+	if equal == nil {
+		return ATestPath(srcNode, path, value)
+	}
+	if value == nil {
+		return jsonNodeError("test", ErrInvalidOperation, "value is nil")
+	}
+	p, err := ParsePointer(path)
+	if err != nil {
+		return jsonNodeError("test", ErrInvalidOperation, err.Error())
+	}
+	f, ok := srcNode.FindPointer(p)
+	if !ok {
+		return jsonNodeError("test", ErrPathNotFound, fmt.Sprintf("%s not find", path))
+	}
+	if !equal(p, f, value) {
+		one, _ := Marshal(f)
+		another, _ := Marshal(value)
+		return jsonNodeError("test", ErrTestFailed, valueAreNotEqual(string(one), string(another)))
+	}
+	return nil
+}
+
+// clone 返回 jn 的深拷贝
+func (jn *JsonNode) clone() *JsonNode {
+	res := *jn
+	switch jn.Type {
+	case JsonNodeTypeSlice:
+		res.Children = make([]*JsonNode, len(jn.Children))
+		for i, child := range jn.Children {
+			res.Children[i] = child.clone()
+		}
+	case JsonNodeTypeObject:
+		res.ChildrenMap = make(map[string]*JsonNode, len(jn.ChildrenMap))
+		for k, v := range jn.ChildrenMap {
+			res.ChildrenMap[k] = v.clone()
+		}
+		res.Keys = jn.ObjectKeys()
+	}
+	return &res
+}
+
+// sliceIndex 将 key 转换为 JsonNodeTypeSlice 的下标，
+// string 类型的 key 必须是不含前导零的十进制数字
+func sliceIndex(op string, key interface{}) (int, error) {
+	switch k := key.(type) {
+	case string:
+		index, ok := arrayIndex(k)
+		if !ok {
+			return 0, jsonNodeError(op, ErrPathNotFound, keyMustCanBeConvertibleToInt(key))
+		}
+		return index, nil
+	case int:
+		return k, nil
+	}
+	return 0, jsonNodeError(op, ErrPathNotFound, keyMustCanBeConvertibleToInt(key))
+}
+
+// splitKey 解析 path 并返回它的最后一个 token 以及父节点，op 用于构造错误
+func splitKey(op string, node *JsonNode, path string) (string, *JsonNode, error) {
+	p, err := ParsePointer(path)
+	if err != nil {
+		return "", nil, jsonNodeError(op, ErrInvalidOperation, err.Error())
+	}
+	if p.IsRoot() {
+		return "", nil, jsonNodeError(op, ErrInvalidOperation, fmt.Sprintf("%s path has no parent", path))
+	}
+	f, ok := node.FindPointer(p.Parent())
 	if !ok {
-		return "", nil, fmt.Errorf("%s path not find", path)
+		return "", nil, jsonNodeError(op, ErrPathNotFound, fmt.Sprintf("%s path not find", path))
 	}
-	return childKey, f, nil
+	return p.Last(), f, nil
 }
 
 func keyMastString(got interface{}) string {
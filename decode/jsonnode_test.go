@@ -18,6 +18,7 @@
 package decode
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -32,10 +33,12 @@ func Test_keyReplace(t *testing.T) {
 	}{
 		{"common", args{key: "article~1a~01~001name"}, "article~01a~001~0001name"},
 		{"common1", args{key: "01~1"}, "01~01"},
-		{"common2", args{key: "0101~"}, "0101~"},
+		{"common2", args{key: "0101~"}, "0101~0"},
 		{"common3", args{key: "0101/01"}, "0101~101"},
 		{"common4", args{key: "0101/01~01"}, "0101~101~001"},
 		{"common5", args{key: "article/name"}, "article~1name"},
+		{"common6", args{key: "~/~"}, "~0~1~0"},
+		{"common7", args{key: "article_name"}, "article_name"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -59,6 +62,8 @@ func Test_keyRestore(t *testing.T) {
 		{"common1", args{key: "article~1name"}, "article/name"},
 		{"common2", args{key: "article_name"}, "article_name"},
 		{"common3", args{key: "article~1a~001~0001name"}, "article/a~01~001name"},
+		{"common4", args{key: "~01"}, "~1"},
+		{"common5", args{key: "~10"}, "/0"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -68,3 +73,107 @@ func Test_keyRestore(t *testing.T) {
 		})
 	}
 }
+
+func TestJsonNode_keyOrder(t *testing.T) {
+	node, _ := Unmarshal([]byte(`{"c": 1, "a": 2, "b": 3}`))
+	_ = node.ADD("d", NewValueNode(4, 1))
+	_ = node.ADD("a", NewValueNode(5, 1))
+	if _, err := node.Remove("c"); err != nil {
+		t.Fatalf("got an error %+v", err)
+	}
+	_ = node.ADD("c", NewValueNode(6, 1))
+	if _, err := node.Replace("b", NewValueNode(7, 1)); err != nil {
+		t.Fatalf("got an error %+v", err)
+	}
+	want := []string{"a", "b", "d", "c"}
+	if got := node.ObjectKeys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+	// 直接修改 ChildrenMap 时，未记录的 key 按字典序排在最后
+	node.ChildrenMap["f"] = NewValueNode(8, 1)
+	node.ChildrenMap["e"] = NewValueNode(9, 1)
+	delete(node.ChildrenMap, "a")
+	want = []string{"b", "d", "c", "e", "f"}
+	if got := node.ObjectKeys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestJsonNode_strictPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		do      func(node *JsonNode) error
+		want    string
+		wantErr bool
+	}{
+		{"add append", func(node *JsonNode) error {
+			return AddPath(node, "/a/-", NewValueNode("x", 2))
+		}, `{"a":["b","c","x"],"d":{"e":"f"}}`, false},
+		{"add out of range", func(node *JsonNode) error {
+			return AddPath(node, "/a/3", NewValueNode("x", 2))
+		}, "", true},
+		{"add leading zero", func(node *JsonNode) error {
+			return AddPath(node, "/a/01", NewValueNode("x", 2))
+		}, "", true},
+		{"add root", func(node *JsonNode) error {
+			return AddPath(node, "", NewValueNode("x", 0))
+		}, `"x"`, false},
+		{"replace missing key", func(node *JsonNode) error {
+			_, err := ReplacePath(node, "/d/g", NewValueNode("x", 2))
+			return err
+		}, "", true},
+		{"replace root", func(node *JsonNode) error {
+			_, err := ReplacePath(node, "", NewSliceNode(nil, 0))
+			return err
+		}, `[]`, false},
+		{"remove root", func(node *JsonNode) error {
+			_, err := RemovePath(node, "")
+			return err
+		}, "", true},
+		{"move into itself", func(node *JsonNode) error {
+			_, err := MovePath(node, "/d", "/d/e")
+			return err
+		}, "", true},
+		{"move to same location", func(node *JsonNode) error {
+			_, err := MovePath(node, "/d", "/d")
+			return err
+		}, `{"a":["b","c"],"d":{"e":"f"}}`, false},
+		{"move array element", func(node *JsonNode) error {
+			_, err := MovePath(node, "/a/0", "/a/1")
+			return err
+		}, `{"a":["c","b"],"d":{"e":"f"}}`, false},
+		{"copy is deep", func(node *JsonNode) error {
+			if err := CopyPath(node, "/d", "/g"); err != nil {
+				return err
+			}
+			_, err := ReplacePath(node, "/g/e", NewValueNode("x", 2))
+			return err
+		}, `{"a":["b","c"],"d":{"e":"f"},"g":{"e":"x"}}`, false},
+		{"test with custom equal", func(node *JsonNode) error {
+			return ATestPathWith(node, "/a", NewSliceNode([]*JsonNode{NewValueNode("c", 2), NewValueNode("b", 2)}, 1),
+				func(p Pointer, a, b *JsonNode) bool {
+					return p.String() == "/a" && len(a.Children) == len(b.Children)
+				})
+		}, `{"a":["b","c"],"d":{"e":"f"}}`, false},
+		{"test with custom equal fails", func(node *JsonNode) error {
+			return ATestPathWith(node, "/d/e", NewValueNode("f", 2), func(p Pointer, a, b *JsonNode) bool {
+				return false
+			})
+		}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, _ := Unmarshal([]byte(`{"a":["b","c"],"d":{"e":"f"}}`))
+			err := tt.do(node)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wantErr %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := string(mustMarshal(node)); got != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
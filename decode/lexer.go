@@ -18,8 +18,9 @@
 package decode
 
 import (
+	"bytes"
 	"github.com/pkg/errors"
-	"strconv"
+	"io"
 )
 
 type jsonTokenType int
@@ -41,13 +42,11 @@ func tokenToBytes(t jsonTokenType, v interface{}, ov []byte) []byte {
 		}
 		return []byte{'f', 'a', 'l', 's', 'e'}
 	case NUMBER:
-		n := strconv.FormatFloat(v.(float64), 'E', -1, 64)
+		n, _ := ToNumber(v)
 		return []byte(n)
 	case STRING:
 		var build builder
-		_ = build.WriteByte('"')
-		build.Write([]byte(v.(string)))
-		_ = build.WriteByte('"')
+		writeQuotedString(&build, v.(string))
 		return build.Bytes()
 	case NULL:
 		return []byte{'n', 'u', 'l', 'l'}
@@ -87,6 +86,7 @@ type jsonToken struct {
 	t             jsonTokenType
 	v             interface{}
 	originalValue []byte
+	off           int64 // token 第一个字节在整个输入中的偏移
 }
 
 func (j *jsonToken) Bytes() []byte {
@@ -97,220 +97,282 @@ func (j *jsonToken) String() string {
 	return string(j.Bytes())
 }
 
-type lexerTokens []*jsonToken
-
-func (l *lexerTokens) append(t jsonTokenType, v interface{}) {
-	*l = append(*l, &jsonToken{t: t, v: v})
+// describe 返回用于错误信息的 token 描述
+func (j *jsonToken) describe() string {
+	switch j.t {
+	case STRING:
+		return "string " + snippetOf(j.String())
+	case NUMBER:
+		return "number " + j.String()
+	case NULL, Boolean:
+		return j.String()
+	case EndDoc:
+		return "end of input"
+	}
+	return "'" + j.String() + "'"
 }
 
-func (l *lexerTokens) appendWithOV(t jsonTokenType, v interface{}, ov []byte) {
-	*l = append(*l, &jsonToken{t: t, v: v, originalValue: ov})
-}
+// minReadSize 是从 io.Reader 读取数据时缓冲区的最小可用空间
+const minReadSize = 4096
 
+// jsonParser 同时承担词法分析和语法分析，语法分析器每次通过 peek 向词法分析器索要一个 token，
+// 词法分析器只在需要时才读取并解析下一个 token，因此不需要事先持有完整的输入。
+//
+// 当 r 为 nil 时，data 就是完整的输入；否则 data 是从 r 中读取的数据的缓冲区，
+// 已经被解析过的数据会在缓冲区扩容时被丢弃。
 type jsonParser struct {
-	data         []byte
-	off          int
-	parserOffset int
-	tokens       lexerTokens
-	jsonNode     *JsonNode
+	r     io.Reader
+	rErr  error  // r 返回的错误，io.EOF 表示输入结束
+	data  []byte // 缓冲区
+	off   int    // 词法分析器在 data 中的读取位置
+	mark  int    // 当前 token 在 data 中的起始位置，读取新数据时会丢弃 mark 之前较早的数据
+	token *jsonToken
+
+	// 以下字段记录 data[0] 在整个输入中的位置，用于计算 SyntaxError 的行列号
+	base      int64 // data[0] 在整个输入中的偏移
+	line      int   // data[0] 之前的换行符数量
+	lineStart int64 // data[0] 之前最后一行的起始偏移
 }
 
 func initLexer(data []byte) *jsonParser {
 	return &jsonParser{
-		data:   data,
-		off:    0,
-		tokens: make([]*jsonToken, 0),
+		data: data,
+		rErr: io.EOF,
+	}
+}
+
+func initStreamLexer(r io.Reader) *jsonParser {
+	return &jsonParser{
+		r:    r,
+		data: make([]byte, 0, minReadSize),
+	}
+}
+
+// fill 从 r 中读取更多的数据到缓冲区，没有更多数据时返回 false
+func (l *jsonParser) fill() bool {
+	if l.rErr != nil {
+		return false
+	}
+	// 丢弃已经解析过的数据，并记录它们包含的换行信息，
+	// mark 之前的 snippetRadius 个字节会被保留下来，用于生成 SyntaxError 的片段
+	if n := l.mark - snippetRadius; n > 0 {
+		discard := l.data[:n]
+		if c := bytes.Count(discard, []byte{'\n'}); c > 0 {
+			l.line += c
+			l.lineStart = l.base + int64(bytes.LastIndexByte(discard, '\n')) + 1
+		}
+		l.base += int64(n)
+		l.data = l.data[:copy(l.data, l.data[n:])]
+		l.off -= n
+		l.mark -= n
+	}
+	if cap(l.data)-len(l.data) < minReadSize {
+		data := make([]byte, len(l.data), 2*cap(l.data)+minReadSize)
+		copy(data, l.data)
+		l.data = data
 	}
+	for {
+		n, err := l.r.Read(l.data[len(l.data):cap(l.data)])
+		l.data = l.data[:len(l.data)+n]
+		if err != nil {
+			l.rErr = err
+			return n > 0
+		}
+		if n > 0 {
+			return true
+		}
+	}
+}
+
+// ensure 确保缓冲区中从读取位置开始至少有 n 个字节，输入不足时返回 false
+func (l *jsonParser) ensure(n int) bool {
+	for l.off+n > len(l.data) {
+		if !l.fill() {
+			return false
+		}
+	}
+	return true
 }
 
-// 词法分析
-func (l *jsonParser) tokenizer() error {
-	data := l.data[l.off:]
-	for i := 0; i < len(data); {
-		b := data[i]
+// slice 返回 data[start:end] 的内容，流式读取时缓冲区会被复用，因此需要复制一份
+func (l *jsonParser) slice(start, end int) []byte {
+	if l.r == nil {
+		return l.data[start:end]
+	}
+	return append([]byte(nil), l.data[start:end]...)
+}
+
+// readError 返回 r 返回的非 io.EOF 错误
+func (l *jsonParser) readError() error {
+	if l.rErr != nil && l.rErr != io.EOF {
+		return errors.WithStack(l.rErr)
+	}
+	return nil
+}
+
+// peek 返回下一个 token 但不消费它
+func (l *jsonParser) peek() (*jsonToken, error) {
+	if l.token == nil {
+		token, err := l.tokenizer()
+		if err != nil {
+			return nil, err
+		}
+		l.token = token
+	}
+	return l.token, nil
+}
+
+// next 消费并返回下一个 token
+func (l *jsonParser) next() (*jsonToken, error) {
+	token, err := l.peek()
+	if err != nil {
+		return nil, err
+	}
+	l.token = nil
+	return token, nil
+}
+
+// 词法分析，返回下一个 token，输入结束时返回 EndDoc
+func (l *jsonParser) tokenizer() (*jsonToken, error) {
+	for {
+		l.mark = l.off
+		if !l.ensure(1) {
+			if err := l.readError(); err != nil {
+				return nil, err
+			}
+			return &jsonToken{t: EndDoc, off: l.offset(l.off)}, nil
+		}
+		b := l.data[l.off]
+		if b == '\n' || b == ' ' || b == '\r' || b == '\t' {
+			l.off++
+			continue
+		}
+		start := l.offset(l.off)
 		switch b {
 		case '{':
-			l.tokens.append(StartObj, nil)
-			i++
 			l.off++
+			return &jsonToken{t: StartObj, off: start}, nil
 		case '}':
-			l.tokens.append(EndObj, nil)
-			i++
 			l.off++
+			return &jsonToken{t: EndObj, off: start}, nil
 		case '[':
-			l.tokens.append(StartArray, nil)
-			i++
 			l.off++
+			return &jsonToken{t: StartArray, off: start}, nil
 		case ']':
-			l.tokens.append(EndArray, nil)
-			i++
 			l.off++
+			return &jsonToken{t: EndArray, off: start}, nil
 		case ':':
-			l.tokens.append(Colon, nil)
-			i++
 			l.off++
+			return &jsonToken{t: Colon, off: start}, nil
 		case ',':
-			l.tokens.append(Comma, nil)
-			i++
 			l.off++
+			return &jsonToken{t: Comma, off: start}, nil
 		case 't', 'f', 'n': // true
-			err := l.tokenizerLiteral(b)
-			if err != nil {
-				return errors.WithStack(err)
-			}
-			i = (*l).off
+			return l.tokenizerLiteral(b)
 		case '"': // string
-			err := l.tokenizerString()
-			if err != nil {
-				return errors.WithStack(err)
-			}
-			i = (*l).off
+			return l.tokenizerString()
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '-': // number
-			err := l.tokenizerNumber()
-			if err != nil {
-				return errors.WithStack(err)
-			}
-			i = (*l).off
-		case '\n', ' ', '\r', 9:
-			i++
-			l.off++
+			return l.tokenizerNumber()
 		default:
-			return errors.WithStack(illegalInput)
+			return nil, l.lexerError(l.off, "value or punctuation")
 		}
 	}
-	l.tokens.append(EndDoc, nil)
-	return nil
+}
+
+// offset 返回 data 中下标 i 在整个输入中的偏移
+func (l *jsonParser) offset(i int) int64 {
+	return l.base + int64(i)
 }
 
 // string = "" | " chars "
 // chars = char | char chars
-// char = any-Unicode-character-except-"-or-\-or- control-character | \" | \\ | \/ | \b | \f | \n | \r | \t | \u
-func (l *jsonParser) tokenizerString() error {
+// char = any-Unicode-character-except-"-or-\-or- control-character | \" | \\ | \/ | \b | \f | \n | \r | \t | \u four-hex-digits
+// token 的值是转义还原后的字符串，originalValue 保存引号内原始的字节序列
+func (l *jsonParser) tokenizerString() (*jsonToken, error) {
 	var build builder
-	// stringBuff := bytes.NewBufferString("")
+	begin := l.offset(l.off)
 	l.off++
-	data := l.data[l.off:]
-	for i := 0; i < len(data); {
-		d := data[i]
-		switch d {
-		case '"': // end string
-			// stringBuff.WriteByte(d)
-			// l.tokens.append(STRING, stringBuff.String())
-			l.tokens.appendWithOV(STRING, build.String(), build.Bytes())
+	escaped := false
+	for l.ensure(1) {
+		d := l.data[l.off]
+		switch {
+		case d == '"': // end string
+			ov := build.Bytes()
+			if escaped {
+				ov = l.slice(l.mark+1, l.off)
+			}
 			l.off++
-			i++
-			return nil
-		case '\n', '\r': // illegal input
-			return errors.New("[json-diff] illegal input")
-		case '\\':
-			_ = build.WriteByte(d)
-			if i+1 < len(data) {
-				escape := data[i+1]
-				// \", \\, \/, \b, \f, \n, \t, \r
-				if escape == '"' || escape == '\\' || escape == '/' || escape == 'b' ||
-					escape == 'f' || escape == 'n' || escape == 't' || escape == 'r' {
-					_ = build.WriteByte(escape)
-					i += 2
-					l.off += 2
-				} else {
-					return illegalInput
-				}
-			} else {
-				return illegalInput
+			return &jsonToken{t: STRING, v: build.String(), originalValue: ov, off: begin}, nil
+		case d < 0x20: // 控制字符必须转义
+			return nil, l.lexerError(l.off, "escaped control character")
+		case d == '\\':
+			// 一个转义序列最长为 12 字节（\uXXXX\uXXXX）
+			l.ensure(12)
+			n, ok := unescape(&build, l.data[l.off:])
+			if !ok {
+				return nil, l.lexerError(l.off, "escape sequence")
 			}
-
+			l.off += n
+			escaped = true
 		default:
 			l.off++
-			i++
 			_ = build.WriteByte(d)
 		}
 	}
-	return errors.New("[json-diff] illegal input")
+	if err := l.readError(); err != nil {
+		return nil, err
+	}
+	return nil, l.lexerError(l.off, "'\"'")
 }
 
-var illegalInput = errors.New("[json-diff] illegal input")
-
-// number = int | int frac | int exp | int frac exp
-// int = digit | digit1-9 digits  | - digit | - digit1-9 digits
-// frac = . digits
-// exp = e digits
-// digits = digit | digit digits
-// e = e | e+ | e-  | E | E+ | E-
-func (l *jsonParser) tokenizerNumber() error {
-	var build builder
-	_ = build.WriteByte(l.data[l.off])
-	frac := false
-	exp := false
-	l.off++
-	data := l.data[l.off:]
-	for i := 0; i < len(data); {
-		d := data[i]
-		switch d {
-		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-			_ = build.WriteByte(d)
-			l.off++
-			i++
-		case '.':
-			if frac || exp {
-				return errors.WithStack(illegalInput)
-			}
-			_ = build.WriteByte(d)
-			frac = true
-			l.off++
-			i++
-		case 'e', 'E':
-			if exp {
-				return errors.WithStack(illegalInput)
-			}
-			_ = build.WriteByte(d)
-			exp = true
+// number = [ minus ] int [ frac ] [ exp ]
+// int = zero | digit1-9 *digit
+// frac = . 1*digit
+// exp = e [ minus | plus ] 1*digit
+// e = e | E
+func (l *jsonParser) tokenizerNumber() (*jsonToken, error) {
+	begin := l.offset(l.off)
+	if l.data[l.off] == '-' {
+		l.off++
+	}
+	if l.ensure(1) && l.data[l.off] == '0' {
+		l.off++
+	} else if err := l.scanDigits(); err != nil {
+		return nil, err
+	}
+	if l.ensure(1) && l.data[l.off] == '.' {
+		l.off++
+		if err := l.scanDigits(); err != nil {
+			return nil, err
+		}
+	}
+	if l.ensure(1) && (l.data[l.off] == 'e' || l.data[l.off] == 'E') {
+		l.off++
+		if l.ensure(1) && (l.data[l.off] == '-' || l.data[l.off] == '+') {
 			l.off++
-			i++
-			// e must be followed by a number
-			if i >= len(data) {
-				return errors.WithStack(illegalInput)
-			}
-			n := data[i]
-			if n == '-' || n == '+' {
-				_ = build.WriteByte(n)
-				i++
-				l.off++
-				if i+1 >= len(l.data) {
-					return errors.WithStack(illegalInput)
-				}
-				nn := data[i]
-				if isDigits(nn) {
-					_ = build.WriteByte(nn)
-					l.off++
-					i++
-				} else {
-					return errors.WithStack(illegalInput)
-				}
-			} else if isDigits(n) {
-				_ = build.WriteByte(n)
-				i++
-				l.off++
-			} else {
-				return errors.WithStack(illegalInput)
-			}
-		default:
-			vS := build.String()
-			v, err := strconv.ParseFloat(vS, 64)
-			if err != nil {
-				return errors.WithStack(illegalInput)
-			}
-			// l.tokens.append(NUMBER, v)
-			l.tokens.appendWithOV(NUMBER, v, build.Bytes())
-			return nil
+		}
+		if err := l.scanDigits(); err != nil {
+			return nil, err
 		}
 	}
-	vS := build.String()
-	v, err := strconv.ParseFloat(vS, 64)
-	if err != nil {
-		return errors.WithStack(illegalInput)
+	if err := l.readError(); err != nil {
+		return nil, err
+	}
+	ov := l.slice(l.mark, l.off)
+	return &jsonToken{t: NUMBER, v: Number(ov), originalValue: ov, off: begin}, nil
+}
+
+// scanDigits 读取至少一个十进制数字
+func (l *jsonParser) scanDigits() error {
+	if !l.ensure(1) || !isDigits(l.data[l.off]) {
+		if err := l.readError(); err != nil {
+			return err
+		}
+		return l.lexerError(l.off, "digit")
+	}
+	for l.ensure(1) && isDigits(l.data[l.off]) {
+		l.off++
 	}
-	// l.tokens.append(NUMBER, v)
-	l.tokens.appendWithOV(NUMBER, v, build.Bytes())
 	return nil
 }
 
@@ -319,46 +381,88 @@ func isDigits(d byte) bool {
 		d == '4' || d == '5' || d == '6' || d == '7' || d == '8' || d == '9'
 }
 
-func (l *jsonParser) literalJudge(bf *builder, lit []byte) error {
-	literalSize := len(lit)
-	if l.off+literalSize > len(l.data) {
-		return illegalInput
-	}
-	get := l.data[l.off : l.off+literalSize]
-	if len(lit) != len(get) {
-		return errors.WithStack(illegalInput)
-	}
+func (l *jsonParser) literalJudge(lit []byte) error {
+	l.ensure(len(lit))
 	for i, b := range lit {
-		if get[i] != b {
-			return errors.WithStack(illegalInput)
+		if l.off+i >= len(l.data) || l.data[l.off+i] != b {
+			if err := l.readError(); err != nil {
+				return err
+			}
+			return l.lexerError(l.off+i, "'"+string(lit)+"'")
 		}
 	}
-	l.off += literalSize
-	bf.Write(get)
+	l.off += len(lit)
 	return nil
 }
 
-func (l *jsonParser) tokenizerLiteral(head byte) error {
-	literalBuffer := &builder{}
+func (l *jsonParser) tokenizerLiteral(head byte) (*jsonToken, error) {
+	begin := l.offset(l.off)
 	switch head {
 	case 'n':
-		err := l.literalJudge(literalBuffer, []byte{'n', 'u', 'l', 'l'})
+		err := l.literalJudge([]byte{'n', 'u', 'l', 'l'})
 		if err != nil {
-			return err
+			return nil, err
 		}
-		l.tokens.append(NULL, nil)
+		return &jsonToken{t: NULL, off: begin}, nil
 	case 'f':
-		err := l.literalJudge(literalBuffer, []byte{'f', 'a', 'l', 's', 'e'})
+		err := l.literalJudge([]byte{'f', 'a', 'l', 's', 'e'})
 		if err != nil {
-			return err
+			return nil, err
 		}
-		l.tokens.append(Boolean, false)
-	case 't':
-		err := l.literalJudge(literalBuffer, []byte{'t', 'r', 'u', 'e'})
+		return &jsonToken{t: Boolean, v: false, off: begin}, nil
+	default:
+		err := l.literalJudge([]byte{'t', 'r', 'u', 'e'})
 		if err != nil {
-			return err
+			return nil, err
 		}
-		l.tokens.append(Boolean, true)
+		return &jsonToken{t: Boolean, v: true, off: begin}, nil
+	}
+}
+
+// lexerError 返回词法分析阶段在 data[i] 处遇到非法字符时的 SyntaxError
+func (l *jsonParser) lexerError(i int, expected string) error {
+	token := "end of input"
+	if i < len(l.data) {
+		token = describeByte(l.data[i])
+	}
+	return errors.WithStack(l.syntaxError(i, token, expected))
+}
+
+// parserError 返回语法分析阶段遇到非预期 token 时的 SyntaxError
+func (l *jsonParser) parserError(token *jsonToken, expected string) error {
+	return errors.WithStack(l.syntaxError(int(token.off-l.base), token.describe(), expected))
+}
+
+// syntaxError 根据缓冲区中的数据计算 data[i] 处的行列号和附近的输入片段
+func (l *jsonParser) syntaxError(i int, token, expected string) *SyntaxError {
+	if i > len(l.data) {
+		i = len(l.data)
+	}
+	line, lineStart := l.line, l.lineStart
+	if n := bytes.Count(l.data[:i], []byte{'\n'}); n > 0 {
+		line += n
+		lineStart = l.offset(bytes.LastIndexByte(l.data[:i], '\n')) + 1
+	}
+	off := l.offset(i)
+	// 尽量读入出错位置之后的一小段数据作为片段，同时保留出错位置之前的数据
+	if i-snippetRadius < l.mark {
+		l.mark = i - snippetRadius
+		if l.mark < 0 {
+			l.mark = 0
+		}
+	}
+	l.ensure(i - l.off + snippetRadius)
+	i = int(off - l.base)
+	snippetStart := int(lineStart - l.base)
+	if snippetStart < 0 {
+		snippetStart = 0
+	}
+	return &SyntaxError{
+		Offset:   off,
+		Line:     line + 1,
+		Column:   int(off-lineStart) + 1,
+		Token:    token,
+		Expected: expected,
+		Snippet:  snippet(l.data[snippetStart:], i-snippetStart),
 	}
-	return nil
 }
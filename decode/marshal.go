@@ -18,11 +18,14 @@
 package decode
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	"sort"
 )
 
-func (jn *JsonNode) marshalValue() (*builder, error) {
+func (jn *JsonNode) marshalValue(canonical bool) (*builder, error) {
 	tokens := &builder{}
 	if jn.Value == nil {
 		tokens.Write(tokenToBytes(NULL, nil, nil))
@@ -30,9 +33,16 @@ func (jn *JsonNode) marshalValue() (*builder, error) {
 	}
 	switch jn.Value.(type) {
 	case string:
+		if canonical {
+			tokens.Write(tokenToBytes(STRING, jn.Value, nil))
+			break
+		}
 		tokens.Write(tokenToBytes(STRING, jn.Value, jn.originalValue))
-	case int, int8, int16, int32, int64, float64,
+	case Number, int, int8, int16, int32, int64, float64,
 		float32, uint, uint8, uint16, uint32, uint64:
+		if n, ok := ToNumber(jn.Value); !ok || !n.valid() {
+			return nil, errors.New(fmt.Sprintf("fail to marshal node: %v is not a valid json number", jn.Value))
+		}
 		tokens.Write(tokenToBytes(NUMBER, jn.Value, jn.originalValue))
 	case bool:
 		tokens.Write(tokenToBytes(Boolean, jn.Value, nil))
@@ -42,11 +52,11 @@ func (jn *JsonNode) marshalValue() (*builder, error) {
 	return tokens, nil
 }
 
-func (jn *JsonNode) marshalArray() (*builder, error) {
+func (jn *JsonNode) marshalArray(canonical bool) (*builder, error) {
 	tokens := &builder{}
 	size := len(jn.Children)
 	for i, child := range jn.Children {
-		childToken, err := child.marshal()
+		childToken, err := child.marshal(canonical)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -58,27 +68,28 @@ func (jn *JsonNode) marshalArray() (*builder, error) {
 	return tokens, nil
 }
 
-func (jn *JsonNode) marshalObject() (*builder, error) {
+func (jn *JsonNode) marshalObject(canonical bool) (*builder, error) {
 	tokens := &builder{}
-	size := len(jn.ChildrenMap)
-	index := 0
-	for key, value := range jn.ChildrenMap {
-		childTokens, err := value.marshalPair(key)
+	keys := jn.ObjectKeys()
+	if canonical {
+		sort.Strings(keys)
+	}
+	for index, key := range keys {
+		childTokens, err := jn.ChildrenMap[key].marshalPair(key, canonical)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
 		tokens.Write(childTokens.Bytes())
-		if index+1 < size {
+		if index+1 < len(keys) {
 			tokens.Write(tokenToBytes(Comma, nil, nil))
 		}
-		index++
 	}
 	return tokens, nil
 }
 
-func (jn *JsonNode) marshalPair(key string) (*builder, error) {
+func (jn *JsonNode) marshalPair(key string, canonical bool) (*builder, error) {
 	tokens := &builder{}
-	value, err := jn.marshal()
+	value, err := jn.marshal(canonical)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -88,18 +99,18 @@ func (jn *JsonNode) marshalPair(key string) (*builder, error) {
 	return tokens, nil
 }
 
-func (jn *JsonNode) marshal() (*builder, error) {
+func (jn *JsonNode) marshal(canonical bool) (*builder, error) {
 	tokens := &builder{}
 	switch jn.Type {
 	case JsonNodeTypeValue:
-		vTokens, err := jn.marshalValue()
+		vTokens, err := jn.marshalValue(canonical)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
 		tokens.Write(vTokens.Bytes())
 	case JsonNodeTypeSlice:
 		tokens.Write(tokenToBytes(StartArray, nil, nil))
-		aTokens, err := jn.marshalArray()
+		aTokens, err := jn.marshalArray(canonical)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -107,7 +118,7 @@ func (jn *JsonNode) marshal() (*builder, error) {
 		tokens.Write(tokenToBytes(EndArray, nil, nil))
 	case JsonNodeTypeObject:
 		tokens.Write(tokenToBytes(StartObj, nil, nil))
-		oTokens, err := jn.marshalObject()
+		oTokens, err := jn.marshalObject(canonical)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -119,7 +130,7 @@ func (jn *JsonNode) marshal() (*builder, error) {
 
 // Marshal 将一个 JsonNode 对象序列化为 Json 字符。
 func (jn *JsonNode) Marshal() ([]byte, error) {
-	tokens, err := jn.marshal()
+	tokens, err := jn.marshal(false)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -131,3 +142,27 @@ func (jn *JsonNode) Marshal() ([]byte, error) {
 func Marshal(node *JsonNode) ([]byte, error) {
 	return node.Marshal()
 }
+
+// MarshalIndent 与 Marshal 相同，但会格式化输出：每个元素单独一行，
+// 以 prefix 开头，并根据嵌套的层级使用若干个 indent 缩进。key 的顺序与数值的原始文本保持不变
+func MarshalIndent(node *JsonNode, prefix, indent string) ([]byte, error) {
+	b, err := node.Marshal()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, prefix, indent); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalCanonical 将 node 序列化为规范形式：对象的 key 按字典序排列，字符串统一转义，数值保持原始文本，
+// 不包含任何空白。内容相同的文档（不考虑 key 的顺序和字符串的转义方式）得到的结果相同，可以用于计算哈希值或比较文本
+func MarshalCanonical(node *JsonNode) ([]byte, error) {
+	tokens, err := node.marshal(true)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return tokens.Bytes(), nil
+}
@@ -53,6 +53,8 @@ func TestMarshal(t *testing.T) {
 		{"only true", "true"},
 		{"only null", "null"},
 		{"only null", `{"a":[1.2]}`},
+		{"escape", `{"a\"b": "\"\\\/\b\f\n\r\t", "c": ["\u0001\u001f"]}`},
+		{"unicode escape", `{"\u4e2d\u6587": "\u00e9\uD83D\uDE00", "b": "中文😀"}`},
 		{"object", `{"a": 1, "b": "123", "c": false, "d": null}`},
 		{"array", `[1, "2", false, null, [1, 2.5, {}], {"a": 1, "b": null}]`},
 		{"complex", `{"a": null, "b": false, "c": "奤","d": [
@@ -93,6 +95,92 @@ func TestMarshal(t *testing.T) {
 	}
 }
 
+func TestMarshalKeepKeyOrder(t *testing.T) {
+	inputs := []string{
+		`{"z":1,"a":2,"m":3}`,
+		`{"b":{"y":[{"k":1,"c":2}],"x":null},"a":"\u00e9"}`,
+		`[{"3":1,"1":2,"2":3},{}]`,
+	}
+	for _, input := range inputs {
+		node, err := Unmarshal([]byte(input))
+		if err != nil {
+			t.Fatalf("got an error %+v", err)
+		}
+		got, err := Marshal(node)
+		if err != nil {
+			t.Fatalf("got an error %+v", err)
+		}
+		if string(got) != input {
+			t.Errorf("want %s, got %s", input, got)
+		}
+	}
+}
+
+func TestUnmarshalEscape(t *testing.T) {
+	args := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"quote", `"a\"b"`, "a\"b"},
+		{"solidus", `"a\/b\\c"`, "a/b\\c"},
+		{"control", `"\b\f\n\r\t"`, "\b\f\n\r\t"},
+		{"unicode", `"\u4e2d\u6587\u00E9"`, "中文é"},
+		{"surrogate pair", `"\ud83d\ude00"`, "😀"},
+		{"lone high surrogate", `"\ud83dx"`, "\ufffdx"},
+		{"lone low surrogate", `"\ude00"`, "\ufffd"},
+		{"raw utf-8", `"中文😀"`, "中文😀"},
+	}
+	for _, arg := range args {
+		t.Run(arg.name, func(st *testing.T) {
+			node, err := Unmarshal([]byte(arg.input))
+			if err != nil {
+				st.Fatalf("got an error %+v", err)
+			}
+			if node.Value != arg.want {
+				st.Errorf("want %q, got %q", arg.want, node.Value)
+			}
+		})
+	}
+}
+
+func TestUnmarshalBadEscape(t *testing.T) {
+	inputs := []string{`"\x"`, `"\u12"`, `"\u12g4"`, `"a\`, "\"a\nb\"", "\"\t\""}
+	for _, input := range inputs {
+		if _, err := Unmarshal([]byte(input)); err == nil {
+			t.Errorf("%q: want an error, got nil", input)
+		}
+	}
+}
+
+func TestMarshalEscape(t *testing.T) {
+	values := []string{
+		"plain", "a\"b", "back\\slash", "line\nbreak", "\t\r\b\f", "\x00\x1f",
+		"中文", "😀", "</script>", "\xff",
+	}
+	for _, v := range values {
+		got, err := Marshal(NewObjectNode("", map[string]*JsonNode{v: NewValueNode(v, 1)}, 0))
+		if err != nil {
+			t.Fatalf("got an error %+v", err)
+		}
+		var dict map[string]string
+		if err := json.Unmarshal(got, &dict); err != nil {
+			t.Fatalf("%q: invalid json %s: %v", v, got, err)
+		}
+		want := strings.ToValidUTF8(v, "\ufffd")
+		if dict[want] != want {
+			t.Errorf("%q: got %s", v, got)
+		}
+		node, err := Unmarshal(got)
+		if err != nil {
+			t.Fatalf("got an error %+v", err)
+		}
+		if node.ChildrenMap[want].Value != want {
+			t.Errorf("round trip: want %q, got %q", want, node.ChildrenMap[want].Value)
+		}
+	}
+}
+
 func compareInterface(a, b interface{}) bool {
 	if a == nil && b == nil {
 		return true
@@ -135,3 +223,43 @@ func compareInterface(a, b interface{}) bool {
 	}
 	return true
 }
+
+func TestMarshalIndent(t *testing.T) {
+	node, err := Unmarshal([]byte(`{"b": [1.50, {}], "a": {"c": "\u4e2d"}, "d": []}`))
+	if err != nil {
+		t.Fatalf("got an error %+v", err)
+	}
+	got, err := MarshalIndent(node, "", "  ")
+	if err != nil {
+		t.Fatalf("got an error %+v", err)
+	}
+	want := "{\n  \"b\": [\n    1.50,\n    {}\n  ],\n  \"a\": {\n    \"c\": \"\\u4e2d\"\n  },\n  \"d\": []\n}"
+	if string(got) != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{"b": [1.50, {"y": 1, "x": 2}], "a": {"c": "中"}, "d": []}`, `{"a":{"c":"中"},"b":[1.50,{"x":2,"y":1}],"d":[]}`},
+		{`{"a": "\"\/", "A": null, "_": true}`, `{"A":null,"_":true,"a":"\"/"}`},
+		{` [ 3, 2, 1 ] `, `[3,2,1]`},
+		{`"x"`, `"x"`},
+	}
+	for _, tt := range tests {
+		node, err := Unmarshal([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("got an error %+v", err)
+		}
+		got, err := MarshalCanonical(node)
+		if err != nil {
+			t.Fatalf("got an error %+v", err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("want %s, got %s", tt.want, got)
+		}
+	}
+}
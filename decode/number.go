@@ -0,0 +1,323 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package decode
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Number 保存 json 中数值的原始文本，反序列化得到的数值都是 Number 类型，
+// 序列化时原样输出，比较时按十进制精确比较，因此不会像 float64 一样丢失精度：
+// 超过 2^53 的整数可以被正确区分，0.1 和 0.10 被认为相等但会保留各自的写法
+type Number string
+
+// maxNumberExponent 是 Number 转换为 big.Int 或 big.Rat 时允许的最大十进制指数，
+// 避免 1e1000000000 这样的输入占用过多内存
+const maxNumberExponent = 1 << 16
+
+func (n Number) String() string {
+	return string(n)
+}
+
+// Float64 将 n 转换为最接近的 float64
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 将 n 转换为 int64，n 必须是整数（如 1、1.0 或 1e3）且不能超出 int64 的范围
+func (n Number) Int64() (int64, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return i, nil
+	}
+	i, err := n.BigInt()
+	if err != nil {
+		return 0, err
+	}
+	if !i.IsInt64() {
+		return 0, errors.Errorf("number %s overflows int64", n)
+	}
+	return i.Int64(), nil
+}
+
+// BigInt 将 n 转换为 big.Int，n 必须是整数
+func (n Number) BigInt() (*big.Int, error) {
+	d, ok := parseDecimal(string(n))
+	if !ok {
+		return nil, errors.Errorf("invalid number %q", string(n))
+	}
+	if d.isZero() {
+		return new(big.Int), nil
+	}
+	if d.exp < int64(len(d.digits)) {
+		return nil, errors.Errorf("number %s is not an integer", n)
+	}
+	if d.exp > maxNumberExponent {
+		return nil, errors.Errorf("number %s is too large", n)
+	}
+	i, _ := new(big.Int).SetString(d.digits+strings.Repeat("0", int(d.exp)-len(d.digits)), 10)
+	if d.neg {
+		i.Neg(i)
+	}
+	return i, nil
+}
+
+// Rat 将 n 精确地转换为 big.Rat
+func (n Number) Rat() (*big.Rat, error) {
+	d, ok := parseDecimal(string(n))
+	if !ok {
+		return nil, errors.Errorf("invalid number %q", string(n))
+	}
+	if d.isZero() {
+		return new(big.Rat), nil
+	}
+	// 数值为 0.digits * 10^exp
+	shift := d.exp - int64(len(d.digits))
+	if shift > maxNumberExponent || shift < -maxNumberExponent {
+		return nil, errors.Errorf("the exponent of number %s is out of range", n)
+	}
+	num, _ := new(big.Int).SetString(d.digits, 10)
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(abs(shift)), nil)
+	r := new(big.Rat)
+	if shift >= 0 {
+		r.SetInt(num.Mul(num, pow))
+	} else {
+		r.SetFrac(num, pow)
+	}
+	if d.neg {
+		r.Neg(r)
+	}
+	return r, nil
+}
+
+// Cmp 按十进制精确比较 n 和 other，n 小于、等于、大于 other 时分别返回 -1、0、1，
+// 不是合法数值的 Number 按原始文本比较
+func (n Number) Cmp(other Number) int {
+	a, okA := parseDecimal(string(n))
+	b, okB := parseDecimal(string(other))
+	if !okA || !okB {
+		return strings.Compare(string(n), string(other))
+	}
+	return a.cmp(b)
+}
+
+// Equal 判断 n 和 other 在十进制下是否精确相等，如 1、1.0 和 1e0 相等
+func (n Number) Equal(other Number) bool {
+	return n.Cmp(other) == 0
+}
+
+// Canonical 返回 n 的规范形式，精确相等的 Number 规范形式相同，
+// 可以用于计算哈希值
+func (n Number) Canonical() string {
+	d, ok := parseDecimal(string(n))
+	if !ok {
+		return string(n)
+	}
+	if d.isZero() {
+		return "0"
+	}
+	sign := ""
+	if d.neg {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s0.%se%d", sign, d.digits, d.exp)
+}
+
+// ToNumber 将 Number 以及 Go 中的整数和浮点数类型转换为 Number，
+// v 不是数值或者是 NaN、Inf 时第二个参数返回 false
+func ToNumber(v interface{}) (Number, bool) {
+	switch n := v.(type) {
+	case Number:
+		return n, true
+	case int:
+		return Number(strconv.FormatInt(int64(n), 10)), true
+	case int8:
+		return Number(strconv.FormatInt(int64(n), 10)), true
+	case int16:
+		return Number(strconv.FormatInt(int64(n), 10)), true
+	case int32:
+		return Number(strconv.FormatInt(int64(n), 10)), true
+	case int64:
+		return Number(strconv.FormatInt(n, 10)), true
+	case uint:
+		return Number(strconv.FormatUint(uint64(n), 10)), true
+	case uint8:
+		return Number(strconv.FormatUint(uint64(n), 10)), true
+	case uint16:
+		return Number(strconv.FormatUint(uint64(n), 10)), true
+	case uint32:
+		return Number(strconv.FormatUint(uint64(n), 10)), true
+	case uint64:
+		return Number(strconv.FormatUint(n, 10)), true
+	case float32:
+		return formatFloat(float64(n), 32)
+	case float64:
+		return formatFloat(n, 64)
+	}
+	return "", false
+}
+
+func formatFloat(f float64, bitSize int) (Number, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", false
+	}
+	return Number(strconv.FormatFloat(f, 'g', -1, bitSize)), true
+}
+
+// valid 判断 n 是否是合法的 json 数值
+func (n Number) valid() bool {
+	_, ok := parseDecimal(string(n))
+	return ok
+}
+
+// valueEqual 比较两个 JsonNodeTypeValue 节点的值，数值按 Number 的规则比较
+func valueEqual(a, b interface{}) bool {
+	na, okA := ToNumber(a)
+	nb, okB := ToNumber(b)
+	if okA || okB {
+		return okA && okB && na.Equal(nb)
+	}
+	return a == b
+}
+
+// decimal 表示十进制数 0.digits * 10^exp，
+// digits 不含前导零和末尾的零，数值为零时 digits 为空
+type decimal struct {
+	neg    bool
+	digits string
+	exp    int64
+}
+
+// maxDecimalExponent 限制指数的绝对值，超过后不再增长，避免溢出
+const maxDecimalExponent = 1 << 60
+
+// parseDecimal 按照 json 数值的语法解析 s
+func parseDecimal(s string) (decimal, bool) {
+	var d decimal
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		d.neg = true
+		i++
+	}
+	start := i
+	for i < len(s) && isDigits(s[i]) {
+		i++
+	}
+	intPart := s[start:i]
+	if intPart == "" || len(intPart) > 1 && intPart[0] == '0' {
+		return d, false
+	}
+	fracPart := ""
+	if i < len(s) && s[i] == '.' {
+		i++
+		start = i
+		for i < len(s) && isDigits(s[i]) {
+			i++
+		}
+		fracPart = s[start:i]
+		if fracPart == "" {
+			return d, false
+		}
+	}
+	var exp int64
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		expNeg := false
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			expNeg = s[i] == '-'
+			i++
+		}
+		start = i
+		for i < len(s) && isDigits(s[i]) {
+			if exp < maxDecimalExponent {
+				exp = exp*10 + int64(s[i]-'0')
+			}
+			i++
+		}
+		if start == i {
+			return d, false
+		}
+		if expNeg {
+			exp = -exp
+		}
+	}
+	if i != len(s) {
+		return d, false
+	}
+	digits := intPart + fracPart
+	lead := 0
+	for lead < len(digits) && digits[lead] == '0' {
+		lead++
+	}
+	digits = strings.TrimRight(digits[lead:], "0")
+	if digits == "" {
+		d.neg = false
+		return d, true
+	}
+	d.digits = digits
+	d.exp = exp + int64(len(intPart)-lead)
+	return d, true
+}
+
+func (d decimal) isZero() bool {
+	return d.digits == ""
+}
+
+func (d decimal) sign() int {
+	switch {
+	case d.isZero():
+		return 0
+	case d.neg:
+		return -1
+	}
+	return 1
+}
+
+func (d decimal) cmp(other decimal) int {
+	sa, sb := d.sign(), other.sign()
+	if sa != sb || sa == 0 {
+		if sa < sb {
+			return -1
+		} else if sa > sb {
+			return 1
+		}
+		return 0
+	}
+	// 符号相同，比较绝对值
+	c := 0
+	switch {
+	case d.exp < other.exp:
+		c = -1
+	case d.exp > other.exp:
+		c = 1
+	default:
+		c = strings.Compare(d.digits, other.digits)
+	}
+	return c * sa
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package decode
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNumber_Cmp(t *testing.T) {
+	tests := []struct {
+		a, b Number
+		want int
+	}{
+		{"1", "1", 0},
+		{"1", "1.0", 0},
+		{"1", "1e0", 0},
+		{"100", "1E2", 0},
+		{"0.1", "0.10", 0},
+		{"0.1", "1e-1", 0},
+		{"0", "-0", 0},
+		{"0", "0.000e10", 0},
+		{"-1.5", "-15e-1", 0},
+		{"9007199254740993", "9007199254740992", 1},
+		{"0.1", "0.1000000000000000055511151231257827", -1},
+		{"-1", "1", -1},
+		{"-2", "-1", -1},
+		{"0", "-0.0001", 1},
+		{"12", "123", -1},
+		{"0.12", "0.123", -1},
+		{"-0.12", "-0.123", 1},
+		{"1e308", "1e309", -1},
+		{"1e99999999999999999999", "1e99999999999999999999", 0},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Cmp(tt.b); got != tt.want {
+			t.Errorf("%s.Cmp(%s): want %d, got %d", tt.a, tt.b, tt.want, got)
+		}
+		if got := tt.b.Cmp(tt.a); got != -tt.want {
+			t.Errorf("%s.Cmp(%s): want %d, got %d", tt.b, tt.a, -tt.want, got)
+		}
+		if got := tt.a.Canonical() == tt.b.Canonical(); got != (tt.want == 0) {
+			t.Errorf("canonical of %s and %s: %s, %s", tt.a, tt.b, tt.a.Canonical(), tt.b.Canonical())
+		}
+	}
+}
+
+func TestNumber_convert(t *testing.T) {
+	if i, err := Number("9223372036854775807").Int64(); err != nil || i != math.MaxInt64 {
+		t.Errorf("Int64: got %d, %v", i, err)
+	}
+	if i, err := Number("1.5e3").Int64(); err != nil || i != 1500 {
+		t.Errorf("Int64: got %d, %v", i, err)
+	}
+	for _, n := range []Number{"9223372036854775808", "1.5", "1e400"} {
+		if _, err := n.Int64(); err == nil {
+			t.Errorf("%s.Int64: want an error", n)
+		}
+	}
+	if i, err := Number("-123456789012345678901234567890").BigInt(); err != nil ||
+		i.String() != "-123456789012345678901234567890" {
+		t.Errorf("BigInt: got %v, %v", i, err)
+	}
+	if i, err := Number("12e20").BigInt(); err != nil || i.String() != "1200000000000000000000" {
+		t.Errorf("BigInt: got %v, %v", i, err)
+	}
+	if _, err := Number("1.25").BigInt(); err == nil {
+		t.Errorf("BigInt: want an error")
+	}
+	if r, err := Number("-0.125").Rat(); err != nil || r.String() != "-1/8" {
+		t.Errorf("Rat: got %v, %v", r, err)
+	}
+	if f, err := Number("0.5").Float64(); err != nil || f != 0.5 {
+		t.Errorf("Float64: got %v, %v", f, err)
+	}
+}
+
+func TestToNumber(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want Number
+	}{
+		{1, "1"},
+		{int64(-9007199254740993), "-9007199254740993"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{1.0, "1"},
+		{0.1, "0.1"},
+		{float32(0.1), "0.1"},
+		{1e21, "1e+21"},
+		{Number("1.50"), "1.50"},
+	}
+	for _, tt := range tests {
+		if got, ok := ToNumber(tt.v); !ok || got != tt.want {
+			t.Errorf("ToNumber(%v): want %s, got %s", tt.v, tt.want, got)
+		}
+	}
+	for _, v := range []interface{}{math.NaN(), math.Inf(1), "1", true, nil} {
+		if _, ok := ToNumber(v); ok {
+			t.Errorf("ToNumber(%v): want false", v)
+		}
+	}
+}
+
+func TestNumber_unmarshal(t *testing.T) {
+	input := `[9007199254740993, 9007199254740992, 0.10, 1E+400, -0.0]`
+	node, err := Unmarshal([]byte(input))
+	if err != nil {
+		t.Fatalf("got an error %+v", err)
+	}
+	if node.Children[0].Equal(node.Children[1]) {
+		t.Errorf("%v should not equal %v", node.Children[0].Value, node.Children[1].Value)
+	}
+	if n, ok := node.Children[2].Value.(Number); !ok || n != "0.10" {
+		t.Errorf("want Number 0.10, got %#v", node.Children[2].Value)
+	}
+	if got := string(mustMarshal(node)); got != `[9007199254740993,9007199254740992,0.10,1E+400,-0.0]` {
+		t.Errorf("got %s", got)
+	}
+	if !node.Children[2].Equal(NewValueNode(0.1, 1)) {
+		t.Errorf("0.10 should equal float64 0.1")
+	}
+	if node.Children[2].Equal(NewValueNode("0.10", 1)) {
+		t.Errorf("0.10 should not equal string 0.10")
+	}
+}
+
+func TestNumber_marshal(t *testing.T) {
+	node := NewSliceNode([]*JsonNode{
+		NewValueNode(1, 1), NewValueNode(int64(-9007199254740993), 1), NewValueNode(0.5, 1),
+		NewValueNode(1e21, 1), NewValueNode(Number("1.50"), 1),
+	}, 0)
+	if got := string(mustMarshal(node)); got != `[1,-9007199254740993,0.5,1e+21,1.50]` {
+		t.Errorf("got %s", got)
+	}
+	for _, v := range []interface{}{math.NaN(), Number("1."), Number("abc")} {
+		if _, err := Marshal(NewValueNode(v, 0)); err == nil {
+			t.Errorf("%v: want an error", v)
+		}
+	}
+}
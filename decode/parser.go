@@ -19,67 +19,34 @@ package decode
 
 import (
 	"github.com/pkg/errors"
+	"io"
 )
 
-var parserError = errors.New("fail to parser json")
-
 // 语法分析器
 // doc = object | array | string | number | true | false | null
-func (l *jsonParser) parser() error {
-	for {
-		if l.parserOffset >= len(l.tokens) {
-			break
-		}
-		token := l.tokens[l.parserOffset]
-		if token.t == EndDoc {
-			break
-		}
-		switch token.t {
-		case StartObj:
-			obj, err := l.parserObj(0)
-			if err != nil {
-				return err
-			}
-			l.jsonNode = obj
-		case StartArray:
-			arr, err := l.parserArray(0)
-			if err != nil {
-				return err
-			}
-			l.jsonNode = arr
-		case Boolean, NULL:
-			node := NewValueNode(token.v, 0)
-			l.parserOffset++
-			l.jsonNode = node
-		case NUMBER, STRING:
-			node := newOriginalValueNode(token.originalValue, token.v, 0)
-			l.parserOffset++
-			l.jsonNode = node
-		default:
-			return parserError
-		}
+// parser 解析输入中的下一个值，输入中没有更多的值时返回 nil
+func (l *jsonParser) parser() (*JsonNode, error) {
+	token, err := l.peek()
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	if token.t == EndDoc {
+		return nil, nil
+	}
+	return l.parseValue(0)
 }
 
 // array = [] | [ elements ]
 func (l *jsonParser) parserArray(level int) (*JsonNode, error) {
-	l.parserOffset++
-	if l.parserOffset >= len(l.tokens) {
-		return nil, parserError
+	_, _ = l.next()
+	token, err := l.peek()
+	if err != nil {
+		return nil, err
 	}
-	token := l.tokens[l.parserOffset]
 	switch token.t {
 	case EndArray:
-		l.parserOffset++
+		_, _ = l.next()
 		return NewSliceNode(make([]*JsonNode, 0), level), nil
-	// case NUMBER:
-	//     node := newOriginalValueNode(token.originalValue, make([]*JsonNode, 0), level)
-	//     err := l.parseElements(level, node)
-	//     if err != nil {
-	//         return nil, err
-	//     }
-	//     return node, nil
 	case STRING, NUMBER, StartObj, StartArray, Boolean, NULL:
 		node := NewSliceNode(make([]*JsonNode, 0), level)
 		err := l.parseElements(level, node)
@@ -88,77 +55,65 @@ func (l *jsonParser) parserArray(level int) (*JsonNode, error) {
 		}
 		return node, nil
 	default:
-		return nil, parserError
+		return nil, l.parserError(token, "value or ']'")
 	}
 }
 
 // elements = value  | value , elements
 func (l *jsonParser) parseElements(level int, parent *JsonNode) error {
 	for {
-		err := l.parseValue(level, parent)
+		childNode, err := l.parseValue(level + 1)
 		if err != nil {
 			return err
 		}
-		if l.parserOffset >= len(l.tokens) {
-			return parserError
+		_ = parent.Append(childNode)
+		first, err := l.next()
+		if err != nil {
+			return err
 		}
-		first := l.tokens[l.parserOffset]
 		if first.t == Comma {
-			l.parserOffset++
 			continue
 		} else if first.t == EndArray {
-			l.parserOffset++
 			break
 		} else {
-			return parserError
+			return l.parserError(first, "',' or ']'")
 		}
 	}
 	return nil
 }
 
 // value = string | number | object | array | true | false | null
-func (l *jsonParser) parseValue(level int, parent *JsonNode) error {
-	if l.parserOffset >= len(l.tokens) {
-		return parserError
+func (l *jsonParser) parseValue(level int) (*JsonNode, error) {
+	token, err := l.peek()
+	if err != nil {
+		return nil, err
 	}
-	token := l.tokens[l.parserOffset]
 	switch token.t {
 	case Boolean, NULL:
-		childNode := NewValueNode(token.v, level+1)
-		_ = parent.Append(childNode)
-		l.parserOffset++
+		_, _ = l.next()
+		return NewValueNode(token.v, level), nil
 	case NUMBER, STRING:
-		childNode := newOriginalValueNode(token.originalValue, token.v, level+1)
-		_ = parent.Append(childNode)
-		l.parserOffset++
+		_, _ = l.next()
+		return newOriginalValueNode(token.originalValue, token.v, level), nil
 	case StartObj:
-		childNode, err := l.parserObj(level + 1)
-		if err != nil {
-			return parserError
-		}
-		_ = parent.Append(childNode)
+		return l.parserObj(level)
 	case StartArray:
-		childNode, err := l.parserArray(level + 1)
-		if err != nil {
-			return parserError
-		}
-		_ = parent.Append(childNode)
+		return l.parserArray(level)
 	default:
-		return parserError
+		return nil, l.parserError(token, "value")
 	}
-	return nil
 }
 
 // object = {} | { members }
 func (l *jsonParser) parserObj(level int) (*JsonNode, error) {
-	l.parserOffset++
-	if l.parserOffset >= len(l.tokens) {
-		return nil, parserError
+	_, _ = l.next()
+	token, err := l.peek()
+	if err != nil {
+		return nil, err
 	}
-	token := l.tokens[l.parserOffset]
 	switch token.t {
 	case EndObj:
-		l.parserOffset++
+		_, _ = l.next()
 		return NewObjectNode("", map[string]*JsonNode{}, level), nil
 	case STRING:
 		node := NewObjectNode("", map[string]*JsonNode{}, level)
@@ -168,7 +123,7 @@ func (l *jsonParser) parserObj(level int) (*JsonNode, error) {
 		}
 		return node, nil
 	default:
-		return nil, parserError
+		return nil, l.parserError(token, "string key or '}'")
 	}
 }
 
@@ -179,77 +134,98 @@ func (l *jsonParser) parseMembers(level int, parent *JsonNode) error {
 		if err != nil {
 			return err
 		}
-		if l.parserOffset >= len(l.tokens) {
-			return parserError
+		first, err := l.next()
+		if err != nil {
+			return err
 		}
-		first := l.tokens[l.parserOffset]
 		if first.t == Comma {
-			l.parserOffset++
 			continue
 		} else if first.t == EndObj {
-			l.parserOffset++
 			break
 		} else {
-			return parserError
+			return l.parserError(first, "',' or '}'")
 		}
 	}
 	return nil
 }
 
 // pair = string : value
-// value = string | number | object | array | true | false | null
 func (l *jsonParser) parsePair(level int, parent *JsonNode) error {
-	// the parser offset pointer in string
-	if l.parserOffset+2 >= len(l.tokens) {
-		return parserError
-	}
-	idx := l.parserOffset
-	first, second, third := l.tokens[idx], l.tokens[idx+1], l.tokens[idx+2]
-	if first.t != STRING || second.t != Colon {
-		return parserError
-	}
-	l.parserOffset += 2
-	k := first.v.(string)
-	switch third.t {
-	case Boolean, NULL:
-		childNode := NewValueNode(third.v, level+1)
-		_ = parent.ADD(k, childNode)
-		l.parserOffset++
-	case NUMBER, STRING:
-		childNode := newOriginalValueNode(third.originalValue, third.v, level+1)
-		_ = parent.ADD(k, childNode)
-		l.parserOffset++
-	case StartObj:
-		childNode, err := l.parserObj(level + 1)
-		if err != nil {
-			return parserError
-		}
-		_ = parent.ADD(k, childNode)
-	case StartArray:
-		childNode, err := l.parserArray(level + 1)
-		if err != nil {
-			return parserError
-		}
-		_ = parent.ADD(k, childNode)
-	default:
-		return parserError
+	first, err := l.next()
+	if err != nil {
+		return err
 	}
+	if first.t != STRING {
+		return l.parserError(first, "string key")
+	}
+	second, err := l.next()
+	if err != nil {
+		return err
+	}
+	if second.t != Colon {
+		return l.parserError(second, "':'")
+	}
+	childNode, err := l.parseValue(level + 1)
+	if err != nil {
+		return err
+	}
+	_ = parent.ADD(first.v.(string), childNode)
 	return nil
 }
 
 // Unmarshal 将一个 json 序列格式化为 JsonNode 对象
+// 输入不合法时返回的 error 中包含 *SyntaxError，可以使用 errors.As 获取出错的位置
 func Unmarshal(input []byte) (*JsonNode, error) {
 	if input == nil {
 		return nil, nil
 	}
 	l := initLexer(input)
-	err := l.tokenizer()
+	node, err := l.parser()
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to Unmarshal")
 	}
-	err = l.parser()
+	// 一个文档只能包含一个值
+	end, err := l.peek()
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to Unmarshal")
 	}
-	return l.jsonNode, nil
+	if end.t != EndDoc {
+		return nil, errors.Wrap(l.parserError(end, "end of input"), "fail to Unmarshal")
+	}
+	return node, nil
+}
+
+// Decoder 从 io.Reader 中按需读取并解析 json 数据，不需要事先将全部输入读入内存，
+// 适用于较大的文件或者网络连接。
+// 输入中可以包含多个连续的顶层 json 值，每次调用 Decode 返回其中的一个。
+type Decoder struct {
+	l   *jsonParser
+	err error
+}
+
+// NewDecoder 返回一个从 r 中读取数据的 Decoder
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{l: initStreamLexer(r)}
+}
+
+// Decode 读取并返回输入中的下一个 json 值，输入结束时返回 io.EOF。
+// 输入不合法时返回的 error 中包含 *SyntaxError，此后的 Decode 都会返回同一个错误。
+func (d *Decoder) Decode() (*JsonNode, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	node, err := d.l.parser()
+	if err != nil {
+		d.err = errors.Wrap(err, "fail to Decode")
+		return nil, d.err
+	}
+	if node == nil {
+		return nil, io.EOF
+	}
+	return node, nil
+}
+
+// InputOffset 返回当前已读取的输入的偏移，即上一个 Decode 返回的值结束的位置
+func (d *Decoder) InputOffset() int64 {
+	return d.l.offset(d.l.off)
 }
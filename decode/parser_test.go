@@ -0,0 +1,178 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package decode
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestUnmarshalSyntaxError(t *testing.T) {
+	args := []struct {
+		name     string
+		input    string
+		offset   int64
+		line     int
+		column   int
+		token    string
+		expected string
+	}{
+		{"missing value", `{"a": }`, 6, 1, 7, "'}'", "value"},
+		{"missing colon", "{\n  \"a\" 1\n}", 8, 2, 7, "number 1", "':'"},
+		{"missing comma", "[1, 2\n 3]", 7, 2, 2, "number 3", "',' or ']'"},
+		{"unclosed object", `{"a": 1`, 7, 1, 8, "end of input", "',' or '}'"},
+		{"bad key", `{1: 2}`, 1, 1, 2, "number 1", "string key or '}'"},
+		{"trailing comma", `[1, ]`, 4, 1, 5, "']'", "value"},
+		{"bad literal", `[tru]`, 4, 1, 5, "']'", "'true'"},
+		{"bad char", "{\"a\": 1,\n\"b\": @}", 14, 2, 6, "'@'", "value or punctuation"},
+		{"bad escape", `["\x"]`, 2, 1, 3, `'\'`, "escape sequence"},
+		{"unterminated string", `"abc`, 4, 1, 5, "end of input", `'"'`},
+		{"control character", "\"a\tb\"", 2, 1, 3, "byte 0x09", "escaped control character"},
+		{"leading zero", `01`, 1, 1, 2, "number 1", "end of input"},
+		{"bad fraction", `1.}`, 2, 1, 3, "'}'", "digit"},
+		{"bad exponent", `1e+`, 3, 1, 4, "end of input", "digit"},
+		{"lone minus", `-`, 1, 1, 2, "end of input", "digit"},
+	}
+	for _, arg := range args {
+		t.Run(arg.name, func(st *testing.T) {
+			_, err := Unmarshal([]byte(arg.input))
+			if err == nil {
+				st.Fatalf("want an error, got nil")
+			}
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				st.Fatalf("want a *SyntaxError, got %v", err)
+			}
+			if se.Offset != arg.offset || se.Line != arg.line || se.Column != arg.column {
+				st.Errorf("want offset %d line %d column %d, got %d %d %d",
+					arg.offset, arg.line, arg.column, se.Offset, se.Line, se.Column)
+			}
+			if se.Token != arg.token || se.Expected != arg.expected {
+				st.Errorf("want token %s expected %s, got %s %s", arg.token, arg.expected, se.Token, se.Expected)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorSnippet(t *testing.T) {
+	input := "{\n  \"name\": \"中文中文中文中文中文\", \"age\": ,\n  \"x\": 1\n}"
+	_, err := Unmarshal([]byte(input))
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		t.Fatalf("want a *SyntaxError, got %v", err)
+	}
+	want := "中文中文\", \"age\": ,"
+	if se.Snippet != want {
+		t.Errorf("want snippet %q, got %q", want, se.Snippet)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	values := []string{
+		`{"a": 1, "b": [1, 2.5e3, "x\"y"], "c": {"d": null, "e": true}}`,
+		`[]`, `"\ud83d\ude00"`, `-0.25`, `12`, `false`, `null`, `{}`,
+	}
+	input := strings.Join(values, "\n ") + "\n"
+	readers := map[string]func() io.Reader{
+		"reader":   func() io.Reader { return strings.NewReader(input) },
+		"one byte": func() io.Reader { return iotest.OneByteReader(strings.NewReader(input)) },
+		"half":     func() io.Reader { return iotest.HalfReader(strings.NewReader(input)) },
+	}
+	for name, newReader := range readers {
+		t.Run(name, func(st *testing.T) {
+			dec := NewDecoder(newReader())
+			for _, v := range values {
+				want, _ := Unmarshal([]byte(v))
+				got, err := dec.Decode()
+				if err != nil {
+					st.Fatalf("got an error %+v", err)
+				}
+				if !got.Equal(want) {
+					st.Errorf("want %s, got %s", v, string(mustMarshal(got)))
+				}
+			}
+			if _, err := dec.Decode(); err != io.EOF {
+				st.Errorf("want io.EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDecoderLargeInput(t *testing.T) {
+	var build strings.Builder
+	build.WriteString("[")
+	for i := 0; i < 5000; i++ {
+		if i > 0 {
+			build.WriteString(",\n")
+		}
+		build.WriteString(`{"id": 1234567, "name": "\u540d\u5b57", "tags": ["a", "b"]}`)
+	}
+	build.WriteString("]")
+	dec := NewDecoder(iotest.HalfReader(strings.NewReader(build.String())))
+	got, err := dec.Decode()
+	if err != nil {
+		t.Fatalf("got an error %+v", err)
+	}
+	want, _ := Unmarshal([]byte(build.String()))
+	if !got.Equal(want) {
+		t.Errorf("decoded value is not equal to Unmarshal")
+	}
+	if dec.InputOffset() != int64(build.Len()) {
+		t.Errorf("want input offset %d, got %d", build.Len(), dec.InputOffset())
+	}
+}
+
+func TestDecoderSyntaxError(t *testing.T) {
+	input := strings.Repeat("{\"a\": [1, 2, 3]}\n", 1000) + "{\"a\": [1, 2,, 3]}"
+	dec := NewDecoder(iotest.OneByteReader(strings.NewReader(input)))
+	for i := 0; i < 1000; i++ {
+		if _, err := dec.Decode(); err != nil {
+			t.Fatalf("got an error %+v", err)
+		}
+	}
+	_, err := dec.Decode()
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		t.Fatalf("want a *SyntaxError, got %v", err)
+	}
+	if se.Line != 1001 || se.Column != 13 || se.Offset != 1000*17+12 {
+		t.Errorf("want line 1001 column 13 offset %d, got %d %d %d", 1000*17+12, se.Line, se.Column, se.Offset)
+	}
+	if se.Snippet != `{"a": [1, 2,, 3]}` {
+		t.Errorf("unexpected snippet %q", se.Snippet)
+	}
+	if _, err2 := dec.Decode(); err2 != err {
+		t.Errorf("want the same error, got %v", err2)
+	}
+}
+
+func TestDecoderReadError(t *testing.T) {
+	dec := NewDecoder(iotest.TimeoutReader(strings.NewReader(`[1, 2, 3`)))
+	_, err := dec.Decode()
+	if !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("want iotest.ErrTimeout, got %v", err)
+	}
+}
+
+func mustMarshal(node *JsonNode) []byte {
+	b, _ := Marshal(node)
+	return b
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package decode
+
+import (
+	"strings"
+)
+
+// Pointer 表示 RFC 6901 定义的 JSON Pointer，如 "/article_list/0/author_info"。
+// Pointer 由若干个 reference token 组成，每个 token 保存的都是转义还原后的 key 或数组下标，
+// 零值表示指向整个文档的 ""。Pointer 是不可变的，Append 和 Parent 都会返回新的 Pointer。
+type Pointer struct {
+	tokens []string
+}
+
+// ParsePointer 解析字符串形式的 JSON Pointer，
+// s 不为空且不以 "/" 开头，或包含 "~0"、"~1" 以外的 "~" 转义时返回 BadPointerError
+func ParsePointer(s string) (Pointer, error) {
+	var p Pointer
+	err := p.Parse(s)
+	return p, err
+}
+
+// Parse 解析字符串形式的 JSON Pointer 并保存到 p 中
+func (p *Pointer) Parse(s string) error {
+	if s == "" {
+		p.tokens = nil
+		return nil
+	}
+	if s[0] != '/' {
+		return pointerError(s, "must start with '/'")
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, token := range tokens {
+		if !validToken(token) {
+			return pointerError(s, "'~' must be followed by '0' or '1'")
+		}
+		tokens[i] = KeyRestore(token)
+	}
+	p.tokens = tokens
+	return nil
+}
+
+func validToken(token string) bool {
+	for i := 0; i < len(token); i++ {
+		if token[i] == '~' && (i+1 >= len(token) || (token[i+1] != '0' && token[i+1] != '1')) {
+			return false
+		}
+	}
+	return true
+}
+
+// String 返回转义后的字符串形式的 JSON Pointer
+func (p Pointer) String() string {
+	var build strings.Builder
+	for _, token := range p.tokens {
+		build.WriteByte('/')
+		build.WriteString(KeyReplace(token))
+	}
+	return build.String()
+}
+
+// Append 返回在 p 之后追加 token 得到的新 Pointer，token 是未转义的 key 或数组下标
+func (p Pointer) Append(token string) Pointer {
+	tokens := make([]string, len(p.tokens)+1)
+	copy(tokens, p.tokens)
+	tokens[len(p.tokens)] = token
+	return Pointer{tokens: tokens}
+}
+
+// Parent 返回指向 p 的父节点的 Pointer，根节点的父节点仍然是根节点
+func (p Pointer) Parent() Pointer {
+	if len(p.tokens) == 0 {
+		return p
+	}
+	n := len(p.tokens) - 1
+	return Pointer{tokens: p.tokens[:n:n]}
+}
+
+// Tokens 返回 p 中所有转义还原后的 token
+func (p Pointer) Tokens() []string {
+	tokens := make([]string, len(p.tokens))
+	copy(tokens, p.tokens)
+	return tokens
+}
+
+// Last 返回 p 的最后一个 token，根节点返回 ""
+func (p Pointer) Last() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+// IsRoot 判断 p 是否指向整个文档
+func (p Pointer) IsRoot() bool {
+	return len(p.tokens) == 0
+}
+
+// IsPrefixOf 判断 p 是否指向 other 本身或 other 的祖先节点
+func (p Pointer) IsPrefixOf(other Pointer) bool {
+	if len(p.tokens) > len(other.tokens) {
+		return false
+	}
+	for i, token := range p.tokens {
+		if other.tokens[i] != token {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex 按照 RFC 6901 将 token 解析为数组下标，
+// 只接受不含前导零的十进制数字，解析失败返回 false
+func arrayIndex(token string) (int, bool) {
+	if token == "" || len(token) > 1 && token[0] == '0' {
+		return 0, false
+	}
+	n := 0
+	for i := 0; i < len(token); i++ {
+		if !isDigits(token[i]) {
+			return 0, false
+		}
+		n = n*10 + int(token[i]-'0')
+		if n > maxArrayIndex {
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+const maxArrayIndex = 1<<31 - 1
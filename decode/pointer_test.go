@@ -0,0 +1,112 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package decode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		input  string
+		tokens []string
+	}{
+		{"", []string{}},
+		{"/", []string{""}},
+		{"/foo", []string{"foo"}},
+		{"/foo/0", []string{"foo", "0"}},
+		{"/a~1b", []string{"a/b"}},
+		{"/m~0n", []string{"m~n"}},
+		{"/~01", []string{"~1"}},
+		{"/c%d/ /g|h/i\\j/k\"l", []string{"c%d", " ", "g|h", "i\\j", "k\"l"}},
+	}
+	for _, tt := range tests {
+		p, err := ParsePointer(tt.input)
+		if err != nil {
+			t.Fatalf("%q: got an error %v", tt.input, err)
+		}
+		if got := p.Tokens(); !reflect.DeepEqual(got, tt.tokens) {
+			t.Errorf("%q: want tokens %q, got %q", tt.input, tt.tokens, got)
+		}
+		if p.String() != tt.input {
+			t.Errorf("want %q, got %q", tt.input, p.String())
+		}
+	}
+	for _, input := range []string{"foo", "/a~", "/a~2b", "#/foo"} {
+		if _, err := ParsePointer(input); !errors.Is(err, BadPointerError) {
+			t.Errorf("%q: want BadPointerError, got %v", input, err)
+		}
+	}
+}
+
+func TestPointer(t *testing.T) {
+	var root Pointer
+	p := root.Append("a/b").Append("~c").Append("0")
+	if p.String() != "/a~1b/~0c/0" {
+		t.Errorf("unexpected pointer %s", p.String())
+	}
+	parent := p.Parent()
+	if parent.String() != "/a~1b/~0c" || parent.Last() != "~c" {
+		t.Errorf("unexpected parent %s", parent.String())
+	}
+	// Append 不会影响原来的 Pointer
+	_ = parent.Append("x")
+	if sibling := parent.Append("1"); p.String() != "/a~1b/~0c/0" || sibling.String() != "/a~1b/~0c/1" {
+		t.Errorf("unexpected pointer %s, %s", p.String(), sibling.String())
+	}
+	if !root.IsRoot() || !root.Parent().IsRoot() || p.IsRoot() {
+		t.Errorf("unexpected IsRoot result")
+	}
+	if !parent.IsPrefixOf(p) || !p.IsPrefixOf(p) || p.IsPrefixOf(parent) || !root.IsPrefixOf(p) {
+		t.Errorf("unexpected IsPrefixOf result")
+	}
+}
+
+func TestJsonNode_Find(t *testing.T) {
+	node, _ := Unmarshal([]byte(`{"foo": ["bar", "baz"], "": 0, "a/b": 1, "m~n": 8, "k": {"01": 2}, "n": 3}`))
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"", "", true},
+		{"/foo/0", `"bar"`, true},
+		{"/", "0", true},
+		{"/a~1b", "1", true},
+		{"/m~0n", "8", true},
+		{"/k/01", "2", true},
+		{"/foo/01", "", false},
+		{"/foo/-", "", false},
+		{"/foo/2", "", false},
+		{"/foo/-1", "", false},
+		{"/n/x", "", false},
+		{"foo", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := node.Find(tt.path)
+		if ok != tt.ok {
+			t.Errorf("%q: want %v, got %v", tt.path, tt.ok, ok)
+			continue
+		}
+		if ok && tt.want != "" && string(mustMarshal(got)) != tt.want {
+			t.Errorf("%q: want %s, got %s", tt.path, tt.want, mustMarshal(got))
+		}
+	}
+}
@@ -38,8 +38,9 @@ func copyValue(src *decode.JsonNode) (*decode.JsonNode, error) {
 }
 
 func copyObject(src *decode.JsonNode) (*decode.JsonNode, error) {
-	res := decode.NewObjectNode("", map[string]*decode.JsonNode{}, int(src.Level))
-	for k, v := range src.ChildrenMap {
+	res := decode.NewObjectNode("", make(map[string]*decode.JsonNode, len(src.ChildrenMap)), int(src.Level))
+	for _, k := range src.ObjectKeys() {
+		v := src.ChildrenMap[k]
 		var newNode *decode.JsonNode
 		var err error
 		switch v.Type {
@@ -59,7 +60,7 @@ func copyObject(src *decode.JsonNode) (*decode.JsonNode, error) {
 				return nil, errors.Wrapf(err, "failed to copy %s of Value type", k)
 			}
 		}
-		res.ChildrenMap[k] = newNode
+		_ = res.ADD(k, newNode)
 	}
 	return res, nil
 }
@@ -28,6 +28,19 @@ func TestDeepCopy_emptyObject(t *testing.T) {
 	}
 }
 
+func TestDeepCopy_keyOrder(t *testing.T) {
+	srcStr := `{"z":{"y":true,"x":[{"b":"1","a":false}]},"a":null}`
+	srcNode, _ := decode.Unmarshal([]byte(srcStr))
+	cp, err := DeepCopy(srcNode)
+	if err != nil {
+		t.Errorf("got an error: %v", err)
+	}
+	got, _ := decode.Marshal(cp)
+	if string(got) != srcStr {
+		t.Errorf("want %s, got %s", srcStr, got)
+	}
+}
+
 func TestDeepCopy(t *testing.T) {
 	fileName := "./test_data/deepcopy_test/deepcopy_test.json"
 	input, err := ioutil.ReadFile(fileName)
@@ -136,3 +149,16 @@ func deepCopySpeedTestHelper(srcNode *decode.JsonNode, t *testing.T, loop int, d
 	spend := (time.Now().UnixNano() - startTime) / 1000000
 	fmt.Printf("do %d loops spend %v ms \n", loop, spend)
 }
+
+func TestDeepCopy_numbers(t *testing.T) {
+	input := `[9007199254740993,0.10,1E+2,-0]`
+	node, _ := decode.Unmarshal([]byte(input))
+	res, err := DeepCopy(node)
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	got, _ := decode.Marshal(res)
+	if string(got) != input {
+		t.Errorf("want %s, got %s", input, got)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"strconv"
+)
+
+// Equal 判断两个 JsonNode 是否相等，options 中影响比较结果的选项（如 UseUnorderedArrayOption、UseExcludeOption、UseComparatorOption、UseToleranceOption）
+// 与 GetDiffNode 中的行为一致，即 Equal 返回 true 时 GetDiffNode 不会输出任何差异
+func Equal(a, b *decode.JsonNode, options ...DiffOption) bool {
+	return newDiffConfig(options).equal(decode.Pointer{}, a, b)
+}
+
+// equal 按照 cfg 比较 path 处的两个节点
+func (cfg *diffConfig) equal(path decode.Pointer, a, b *decode.JsonNode) bool {
+	if !cfg.customEqual() {
+		return a.Equal(b)
+	}
+	state := cfg.filter(path)
+	if state == filterSkip {
+		return true
+	}
+	if c, ok := cfg.comparator(path, a, b); ok && state == filterKeep {
+		equal, _ := c.Compare(path.String(), a, b)
+		return equal
+	}
+	// 祖先节点本身的增删改不参与比较
+	if a == nil || b == nil {
+		return state == filterDescend || a == nil && b == nil
+	}
+	if a.Type != b.Type {
+		return state == filterDescend
+	}
+	switch a.Type {
+	case decode.JsonNodeTypeObject:
+		for key, av := range a.ChildrenMap {
+			childPath := path.Append(key)
+			bv, ok := b.ChildrenMap[key]
+			if !ok {
+				if cfg.filter(childPath) == filterKeep {
+					return false
+				}
+				continue
+			}
+			if !cfg.equal(childPath, av, bv) {
+				return false
+			}
+		}
+		for key := range b.ChildrenMap {
+			if _, ok := a.ChildrenMap[key]; !ok && cfg.filter(path.Append(key)) == filterKeep {
+				return false
+			}
+		}
+		return true
+	case decode.JsonNodeTypeSlice:
+		if state == filterDescend {
+			for i := 0; i < len(a.Children) && i < len(b.Children); i++ {
+				if !cfg.equal(path.Append(strconv.Itoa(i)), a.Children[i], b.Children[i]) {
+					return false
+				}
+			}
+			return true
+		}
+		if cfg.elementsExcluded(path) {
+			return true
+		}
+		if len(a.Children) != len(b.Children) {
+			return false
+		}
+		if cfg.unordered(path) {
+			for _, j := range cfg.matchUnordered(path, a, b) {
+				if j < 0 {
+					return false
+				}
+			}
+			return true
+		}
+		for i, av := range a.Children {
+			if !cfg.equal(path.Append(strconv.Itoa(i)), av, b.Children[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return state == filterDescend || cfg.valueEqual(path, a, b)
+}
+
+// customEqual 判断 cfg 中是否有影响相等性判断的选项，没有时直接使用 JsonNode.Equal
+func (cfg *diffConfig) customEqual() bool {
+	return cfg.unorderedAll || len(cfg.unorderedSet) > 0 || cfg.hasInclude || len(cfg.excludes) > 0 ||
+		len(cfg.pathComparators) > 0 || len(cfg.typeComparators) > 0 ||
+		len(cfg.tolerances) > 0
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"fmt"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+)
+
+// 应用差异失败的原因，可以使用 errors.Is 判断 MergeDiff 和 MergeDiffNode 返回的错误
+var (
+	// ErrPathNotFound path 或 from 指向的节点（或它的父节点）不存在
+	ErrPathNotFound = decode.ErrPathNotFound
+
+	// ErrIndexOutOfRange 数组下标超出范围
+	ErrIndexOutOfRange = decode.ErrIndexOutOfRange
+
+	// ErrTestFailed test 操作的值不相等
+	ErrTestFailed = decode.ErrTestFailed
+
+	// ErrInvalidOperation 操作本身不合法，如缺少必需的字段、op 未知、path 不是合法的 JSON Pointer 等
+	ErrInvalidOperation = decode.ErrInvalidOperation
+)
+
+// ErrMergePatchUnrepresentable 表示两个文档之间的差异无法用 JSON Merge Patch (RFC 7396) 表示，
+// 如把某个字段的值设置为 null，或者新的对象中包含值为 null 的字段
+var ErrMergePatchUnrepresentable = errors.New("the change cannot be represented by a merge patch")
+
+// ErrComposeNeedsBase 表示几个差异在没有源文档的情况下无法安全地合并，如包含数组下标、move 或 copy 操作，
+// 此时应当使用 ComposePatches
+var ErrComposeNeedsBase = errors.New("the patches cannot be composed without the base document")
+
+// ErrMergeConflict 表示三方合并时 ours 和 theirs 对同一个节点做了不同的修改，
+// 使用 MergeStrategyFail 时 Merge3 返回由它装饰的 error
+var ErrMergeConflict = errors.New("merge conflict")
+
+// PatchError 描述差异列表中应用失败的那一个操作，
+// MergeDiff 和 MergeDiffNode 返回的错误可以使用 errors.As 获取
+type PatchError struct {
+	Index  int    // 失败的操作在差异列表中的下标，从 0 开始
+	Op     string // 失败的操作类型，字段缺失或不是字符串时为空
+	Path   string // 失败的操作的 path，字段缺失或不是字符串时为空
+	From   string // 失败的操作的 from，只有 move 和 copy 操作有该字段
+	Reason error  // 失败的原因，是 ErrPathNotFound、ErrIndexOutOfRange、ErrTestFailed 和 ErrInvalidOperation 之一
+	Err    error  // 原始的错误
+}
+
+func (e *PatchError) Error() string {
+	op := fmt.Sprintf("op %q, path %q", e.Op, e.Path)
+	if e.From != "" {
+		op += fmt.Sprintf(", from %q", e.From)
+	}
+	return fmt.Sprintf("fail to apply diff %d (%s): %s: %v", e.Index, op, e.Reason, e.Err)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使 errors.Is(err, e.Reason) 总是成立
+func (e *PatchError) Is(target error) bool {
+	return target == e.Reason
+}
+
+func newPatchError(index int, diff *decode.JsonNode, err error) *PatchError {
+	pe := &PatchError{Index: index, Reason: reasonOf(err), Err: err}
+	if diff != nil && diff.Type == decode.JsonNodeTypeObject {
+		pe.Op, _ = diffString(diff, "op")
+		pe.Path, _ = diffString(diff, "path")
+		pe.From, _ = diffString(diff, "from")
+	}
+	return pe
+}
+
+// reasonOf 返回 err 对应的失败原因，无法识别的错误都被认为是 ErrInvalidOperation
+func reasonOf(err error) error {
+	for _, reason := range []error{ErrPathNotFound, ErrIndexOutOfRange, ErrTestFailed} {
+		if errors.Is(err, reason) {
+			return reason
+		}
+	}
+	return ErrInvalidOperation
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestPatchError(t *testing.T) {
+	source := `{"a": [1, 2], "b": {"c": 1}}`
+	tests := []struct {
+		diffs string
+		want  PatchError
+	}{
+		{`[{"op": "test", "path": "/a/0", "value": 1}, {"op": "remove", "path": "/x"}]`,
+			PatchError{Index: 1, Op: "remove", Path: "/x", Reason: ErrPathNotFound}},
+		{`[{"op": "add", "path": "/a/3", "value": 1}]`,
+			PatchError{Index: 0, Op: "add", Path: "/a/3", Reason: ErrIndexOutOfRange}},
+		{`[{"op": "replace", "path": "/a/2", "value": 1}]`,
+			PatchError{Index: 0, Op: "replace", Path: "/a/2", Reason: ErrIndexOutOfRange}},
+		{`[{"op": "test", "path": "/b/c", "value": 2}]`,
+			PatchError{Index: 0, Op: "test", Path: "/b/c", Reason: ErrTestFailed}},
+		{`[{"op": "move", "from": "/b", "path": "/b/d"}]`,
+			PatchError{Index: 0, Op: "move", Path: "/b/d", From: "/b", Reason: ErrInvalidOperation}},
+		{`[{"op": "copy", "from": "/x", "path": "/y"}]`,
+			PatchError{Index: 0, Op: "copy", Path: "/y", From: "/x", Reason: ErrPathNotFound}},
+		{`[{"op": "add", "path": "a", "value": 1}]`,
+			PatchError{Index: 0, Op: "add", Path: "a", Reason: ErrInvalidOperation}},
+		{`[{"op": "add", "path": "/a/-"}]`,
+			PatchError{Index: 0, Op: "add", Path: "/a/-", Reason: ErrInvalidOperation}},
+		{`[{"op": "spam", "path": "/a"}]`,
+			PatchError{Index: 0, Op: "spam", Path: "/a", Reason: ErrInvalidOperation}},
+		{`[{"op": 1, "path": "/a"}]`,
+			PatchError{Index: 0, Path: "/a", Reason: ErrInvalidOperation}},
+		{`[{"op": "remove"}]`,
+			PatchError{Index: 0, Op: "remove", Reason: ErrInvalidOperation}},
+		{`[{"op": "move", "path": "/a", "from": null}]`,
+			PatchError{Index: 0, Op: "move", Path: "/a", Reason: ErrInvalidOperation}},
+		{`[{"op": "remove", "path": "/b"}, 1]`,
+			PatchError{Index: 1, Reason: ErrInvalidOperation}},
+	}
+	for _, tt := range tests {
+		_, err := MergeDiff([]byte(source), []byte(tt.diffs))
+		var pe *PatchError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: want a PatchError, got %v", tt.diffs, err)
+			continue
+		}
+		if pe.Index != tt.want.Index || pe.Op != tt.want.Op || pe.Path != tt.want.Path ||
+			pe.From != tt.want.From || pe.Reason != tt.want.Reason {
+			t.Errorf("%s: want %+v, got %+v", tt.diffs, tt.want, *pe)
+		}
+		if !errors.Is(err, tt.want.Reason) {
+			t.Errorf("%s: errors.Is(err, %v) should be true", tt.diffs, tt.want.Reason)
+		}
+	}
+}
+
+func TestMergeDiffNode_malformed(t *testing.T) {
+	source, _ := decode.Unmarshal([]byte(`{"a": [1, 2]}`))
+	op := func(children map[string]*decode.JsonNode) *decode.JsonNode {
+		return decode.NewObjectNode("", children, 1)
+	}
+	str := func(s string) *decode.JsonNode {
+		return decode.NewValueNode(s, 2)
+	}
+	tests := []*decode.JsonNode{
+		decode.NewValueNode("x", 0),
+		decode.NewSliceNode([]*decode.JsonNode{nil}, 0),
+		decode.NewSliceNode([]*decode.JsonNode{op(map[string]*decode.JsonNode{"op": nil, "path": str("/a")})}, 0),
+		decode.NewSliceNode([]*decode.JsonNode{op(map[string]*decode.JsonNode{"op": str("add"), "path": nil})}, 0),
+		decode.NewSliceNode([]*decode.JsonNode{op(map[string]*decode.JsonNode{
+			"op": str("add"), "path": str("/b"), "value": nil})}, 0),
+		decode.NewSliceNode([]*decode.JsonNode{op(map[string]*decode.JsonNode{
+			"op": str("test"), "path": str("/a"), "value": nil})}, 0),
+		decode.NewSliceNode([]*decode.JsonNode{op(map[string]*decode.JsonNode{
+			"op": str("copy"), "path": str("/b"), "from": decode.NewSliceNode(nil, 2)})}, 0),
+		decode.NewSliceNode([]*decode.JsonNode{op(map[string]*decode.JsonNode{
+			"op": str("replace"), "path": str(""), "value": nil})}, 0),
+	}
+	for i, diffs := range tests {
+		if _, err := MergeDiffNode(source, diffs); err == nil {
+			t.Errorf("case %d: want an error", i)
+		}
+	}
+}
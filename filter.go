@@ -0,0 +1,183 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"fmt"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"strconv"
+)
+
+// filterState 表示一个路径在 include 和 exclude 规则下的状态
+type filterState int
+
+const (
+	// filterKeep 路径被包含，比较它以及它的所有子路径
+	filterKeep filterState = iota
+	// filterDescend 路径本身没有被包含，但它的某些子路径可能被包含，
+	// 只递归比较类型相同的容器，该路径本身的增删改不输出
+	filterDescend
+	// filterSkip 路径被排除，不比较
+	filterSkip
+)
+
+// filterOption 保存 UseIncludeOption 和 UseExcludeOption 的 pattern
+type filterOption struct {
+	include  bool
+	patterns [][]string
+}
+
+func (o filterOption) apply(cfg *diffConfig) {
+	if o.include {
+		cfg.hasInclude = true
+		cfg.includes = append(cfg.includes, o.patterns...)
+	} else {
+		cfg.excludes = append(cfg.excludes, o.patterns...)
+	}
+}
+
+// UseIncludeOption 只比较路径匹配 patterns 的节点及其子节点。
+// pattern 是一个 JSON Pointer，其中 "*" 匹配任意一个 token，"**" 匹配任意多个（包括零个） token，
+// 如 UseIncludeOption("/spec", "/items/*/name")；pattern 不是合法的 JSON Pointer 时 panic。
+// 被包含节点的祖先节点只会递归比较类型相同的容器，它们本身的增删改不会输出，数组按下标一一比较。
+// 该选项同样作用于 Equal
+func UseIncludeOption(patterns ...string) DiffOption {
+	if len(patterns) == 0 {
+		return filterOption{}
+	}
+	return filterOption{include: true, patterns: parsePatterns(patterns)}
+}
+
+// UseExcludeOption 不比较路径匹配 patterns 的节点及其子节点，pattern 的格式与 UseIncludeOption 相同，
+// 如 UseExcludeOption("/metadata/updatedAt", "/items/*/etag", "/status")。
+// 同时设置 include 和 exclude 时 exclude 优先。该选项同样作用于 Equal
+func UseExcludeOption(patterns ...string) DiffOption {
+	return filterOption{patterns: parsePatterns(patterns)}
+}
+
+// parsePatterns 解析 patterns，有不合法的 pattern 时 panic
+func parsePatterns(patterns []string) [][]string {
+	res := make([][]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		res = append(res, mustParsePattern(pattern))
+	}
+	return res
+}
+
+// mustParsePattern 把 pattern 解析为 token 列表，pattern 不是合法的 JSON Pointer 时 panic，
+// 与 regexp.MustCompile 相同，使拼写错误（如缺少开头的 "/"）在创建选项时就能被发现
+func mustParsePattern(pattern string) []string {
+	p, err := decode.ParsePointer(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("json_diff: invalid pattern %q: %v", pattern, err))
+	}
+	return p.Tokens()
+}
+
+// filter 返回 path 在 include 和 exclude 规则下的状态
+func (cfg *diffConfig) filter(path decode.Pointer) filterState {
+	if !cfg.hasInclude && len(cfg.excludes) == 0 {
+		return filterKeep
+	}
+	tokens := path.Tokens()
+	for _, pattern := range cfg.excludes {
+		if matchGlobAncestor(pattern, tokens) {
+			return filterSkip
+		}
+	}
+	if !cfg.hasInclude {
+		return filterKeep
+	}
+	state := filterSkip
+	for _, pattern := range cfg.includes {
+		if matchGlobAncestor(pattern, tokens) {
+			return filterKeep
+		}
+		if matchGlobPrefix(pattern, tokens) {
+			state = filterDescend
+		}
+	}
+	return state
+}
+
+// elementsExcluded 判断 path 处数组的所有元素是否都被排除
+func (cfg *diffConfig) elementsExcluded(path decode.Pointer) bool {
+	return len(cfg.excludes) > 0 && cfg.filter(path.Append("*")) == filterSkip
+}
+
+// diffAncestor 比较 filterDescend 状态的两个节点：对象递归比较每个 key，
+// 数组按下标一一比较，其他情况不输出任何差异
+func diffAncestor(diffs *diffs, path decode.Pointer, source, patch *decode.JsonNode, cfg *diffConfig) {
+	if source == nil || patch == nil || source.Type != patch.Type {
+		return
+	}
+	switch source.Type {
+	case decode.JsonNodeTypeObject:
+		diffObject(diffs, path, source, patch, cfg)
+	case decode.JsonNodeTypeSlice:
+		for i := 0; i < len(source.Children) && i < len(patch.Children); i++ {
+			diff(diffs, path.Append(strconv.Itoa(i)), source.Children[i], patch.Children[i], cfg)
+		}
+	}
+}
+
+// matchGlobAncestor 判断 tokens 或它的某个祖先是否匹配 pattern
+func matchGlobAncestor(pattern, tokens []string) bool {
+	for i := 0; i <= len(tokens); i++ {
+		if matchGlob(pattern, tokens[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob 判断 tokens 是否匹配 pattern，"*" 匹配任意一个 token，"**" 匹配任意多个 token
+func matchGlob(pattern, tokens []string) bool {
+	if len(pattern) == 0 {
+		return len(tokens) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(tokens); i++ {
+			if matchGlob(pattern[1:], tokens[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(tokens) == 0 || (pattern[0] != "*" && pattern[0] != tokens[0]) {
+		return false
+	}
+	return matchGlob(pattern[1:], tokens[1:])
+}
+
+// matchGlobPrefix 判断 tokens 的某个子路径是否可能匹配 pattern
+func matchGlobPrefix(pattern, tokens []string) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	if len(pattern) == 0 {
+		return false
+	}
+	if pattern[0] == "**" {
+		return true
+	}
+	if pattern[0] != "*" && pattern[0] != tokens[0] {
+		return false
+	}
+	return matchGlobPrefix(pattern[1:], tokens[1:])
+}
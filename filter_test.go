@@ -0,0 +1,198 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"fmt"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"strings"
+	"testing"
+)
+
+func TestFilterOption(t *testing.T) {
+	src := `{
+		"metadata": {"name": "app", "updatedAt": "2021-01-01", "labels": {"a": "1"}},
+		"items": [{"name": "x", "etag": "1", "v": 1}, {"name": "y", "etag": "2", "v": 2}],
+		"status": {"phase": "Running"}
+	}`
+	tar := `{
+		"metadata": {"name": "app", "updatedAt": "2021-02-02", "labels": {"a": "2"}},
+		"items": [{"name": "x", "etag": "3", "v": 1}, {"name": "y", "etag": "4", "v": 3}],
+		"status": {"phase": "Failed", "reason": "OOM"}
+	}`
+	tests := []struct {
+		name    string
+		options []DiffOption
+		want    string
+	}{
+		{
+			"exclude",
+			[]DiffOption{UseExcludeOption("/metadata/updatedAt", "/items/*/etag", "/status")},
+			`[{"op":"replace","path":"/metadata/labels/a","value":"2"},{"op":"replace","path":"/items/1/v","value":3}]`,
+		},
+		{
+			"exclude with **",
+			[]DiffOption{UseExcludeOption("/**/etag", "/**/updatedAt", "/status", "/metadata/labels")},
+			`[{"op":"replace","path":"/items/1/v","value":3}]`,
+		},
+		{
+			"include",
+			[]DiffOption{UseIncludeOption("/status")},
+			`[{"op":"replace","path":"/status/phase","value":"Failed"},{"op":"add","path":"/status/reason","value":"OOM"}]`,
+		},
+		{
+			"include with *",
+			[]DiffOption{UseIncludeOption("/items/*/v", "/metadata/**/a")},
+			`[{"op":"replace","path":"/metadata/labels/a","value":"2"},{"op":"replace","path":"/items/1/v","value":3}]`,
+		},
+		{
+			"include and exclude",
+			[]DiffOption{UseIncludeOption("/metadata"), UseExcludeOption("/metadata/updatedAt")},
+			`[{"op":"replace","path":"/metadata/labels/a","value":"2"}]`,
+		},
+		{
+			"exclude everything",
+			[]DiffOption{UseExcludeOption("/**")},
+			`[]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := AsDiffs([]byte(src), []byte(tar), tt.options...)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if string(diffs) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, diffs)
+			}
+			res, err := MergeDiff([]byte(src), diffs)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			got, _ := decode.Unmarshal(res)
+			want, _ := decode.Unmarshal([]byte(tar))
+			if !Equal(got, want, tt.options...) {
+				t.Errorf("filtered result %s is not equal to %s", res, tar)
+			}
+		})
+	}
+}
+
+func TestFilterOption_arrays(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		tar     string
+		options []DiffOption
+		want    string
+	}{
+		{
+			"excluded fields do not break alignment",
+			`[{"id": 1, "etag": "a"}, {"id": 2, "etag": "b"}]`,
+			`[{"id": 0, "etag": "z"}, {"id": 1, "etag": "c"}, {"id": 2, "etag": "d"}]`,
+			[]DiffOption{UseExcludeOption("/*/etag")},
+			`[{"op":"add","path":"/0","value":{"id":0,"etag":"z"}}]`,
+		},
+		{
+			"all elements excluded",
+			`{"a": [1, 2, 3]}`,
+			`{"a": [4]}`,
+			[]DiffOption{UseExcludeOption("/a/*")},
+			`[]`,
+		},
+		{
+			"ancestors are compared by index",
+			`{"items": [{"name": "a", "v": 1}]}`,
+			`{"items": [{"name": "b", "v": 2}, {"name": "c"}]}`,
+			[]DiffOption{UseIncludeOption("/items/*/name")},
+			`[{"op":"replace","path":"/items/0/name","value":"b"}]`,
+		},
+		{
+			"ancestor type changes are ignored",
+			`{"a": 1}`,
+			`{"a": {"b": 1}}`,
+			[]DiffOption{UseIncludeOption("/a/b")},
+			`[]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := AsDiffs([]byte(tt.src), []byte(tt.tar), tt.options...)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if string(diffs) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, diffs)
+			}
+		})
+	}
+}
+
+func TestEqual_filter(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		options []DiffOption
+		want    bool
+	}{
+		{`{"a": 1, "b": 1}`, `{"a": 1, "b": 2}`, []DiffOption{UseExcludeOption("/b")}, true},
+		{`{"a": 1, "b": 1}`, `{"a": 2, "b": 1}`, []DiffOption{UseExcludeOption("/b")}, false},
+		{`{"a": 1}`, `{"a": 1, "b": 2}`, []DiffOption{UseExcludeOption("/b")}, true},
+		{`{"a": {"x": 1, "y": 1}}`, `{"a": {"x": 1, "y": 2}}`, []DiffOption{UseIncludeOption("/a/x")}, true},
+		{`{"a": {"x": 1, "y": 1}}`, `{"a": {"x": 2, "y": 1}}`, []DiffOption{UseIncludeOption("/a/x")}, false},
+		{`{"a": {"x": 1}}`, `{"a": {}}`, []DiffOption{UseIncludeOption("/a/x")}, false},
+		{`[{"t": 1, "v": 1}]`, `[{"t": 2, "v": 1}]`, []DiffOption{UseExcludeOption("/**/t")}, true},
+		{`[1, 2]`, `[2, 1, 3]`, []DiffOption{UseExcludeOption("/*")}, true},
+	}
+	for _, tt := range tests {
+		a, _ := decode.Unmarshal([]byte(tt.a))
+		b, _ := decode.Unmarshal([]byte(tt.b))
+		if got := Equal(a, b, tt.options...); got != tt.want {
+			t.Errorf("Equal(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := len(GetDiffNode(a, b, tt.options...).Children) == 0; got != tt.want {
+			t.Errorf("GetDiffNode(%s, %s) is empty = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestOption_invalidPattern(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func(pattern string) DiffOption
+	}{
+		{"array key", func(pattern string) DiffOption { return UseArrayKeyOption(pattern, "id") }},
+		{"unordered", func(pattern string) DiffOption { return UseUnorderedArrayOption("/tags", pattern) }},
+		{"include", func(pattern string) DiffOption { return UseIncludeOption(pattern) }},
+		{"exclude", func(pattern string) DiffOption { return UseExcludeOption(pattern) }},
+		{"comparator", func(pattern string) DiffOption {
+			return UseComparatorOption(pattern, ComparatorFunc(func(path string, a, b *decode.JsonNode) bool { return true }))
+		}},
+		{"tolerance", func(pattern string) DiffOption { return UseToleranceOption(0.1, 0, pattern) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.new("/users/*")
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), `invalid pattern "users/*"`) {
+					t.Errorf("want a panic for an invalid pattern, got %v", r)
+				}
+			}()
+			tt.new("users/*")
+		})
+	}
+}
@@ -30,7 +30,12 @@ func setHash(node *decode.JsonNode) string {
 	case decode.JsonNodeTypeSlice:
 		hashCode = setSliceHash(node)
 	case decode.JsonNodeTypeValue:
-		hashCode = hash(node.Value)
+		// 精确相等的数值哈希值相同，并且不会与内容相同的字符串冲突
+		if n, ok := decode.ToNumber(node.Value); ok {
+			hashCode = hash("number:" + n.Canonical())
+		} else {
+			hashCode = hash(node.Value)
+		}
 		node.Hash = hashCode
 	}
 	return hashCode
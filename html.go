@@ -0,0 +1,419 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"fmt"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// HTMLOptions 控制 RenderHTML 的输出
+type HTMLOptions struct {
+	// Title 是页面的标题，为空时使用 JSON Diff
+	Title string
+	// DiffOptions 是比较两个文档时传给 GetDiffNode 的选项
+	DiffOptions []DiffOption
+}
+
+const (
+	htmlAdded   = "added"
+	htmlRemoved = "removed"
+	htmlChanged = "changed"
+	htmlMoved   = "moved"
+)
+
+// htmlNode 是 HTML 报告中树上的一个节点，记录节点的状态以及指向它的差异锚点
+type htmlNode struct {
+	key      string
+	node     *decode.JsonNode
+	children []*htmlNode
+	status   string
+	anchors  []string
+	// origin 是目标文档中的节点在源文档中对应的节点
+	origin *htmlNode
+}
+
+func newHTMLNode(key string, node *decode.JsonNode) *htmlNode {
+	res := &htmlNode{key: key, node: node}
+	switch node.Type {
+	case decode.JsonNodeTypeSlice:
+		for _, child := range node.Children {
+			res.children = append(res.children, newHTMLNode("", child))
+		}
+	case decode.JsonNodeTypeObject:
+		for _, k := range node.ObjectKeys() {
+			res.children = append(res.children, newHTMLNode(k, node.ChildrenMap[k]))
+		}
+	}
+	return res
+}
+
+// shadow 返回 hn 的拷贝，拷贝中的每个节点都使用 origin 指向 hn 中对应的节点
+func (hn *htmlNode) shadow() *htmlNode {
+	res := &htmlNode{key: hn.key, node: hn.node, origin: hn}
+	for _, child := range hn.children {
+		res.children = append(res.children, child.shadow())
+	}
+	return res
+}
+
+func (hn *htmlNode) mark(status, anchor string) {
+	if hn.status == "" || status == htmlRemoved {
+		hn.status = status
+	}
+	hn.anchors = append(hn.anchors, anchor)
+}
+
+// locate 返回 tokens 对应节点的父节点以及它在父节点中的下标，
+// 数组下标为 "-" 或对象中不存在该 key 时返回的下标等于 len(parent.children)
+func (hn *htmlNode) locate(tokens []string) (*htmlNode, int, bool) {
+	if len(tokens) == 0 {
+		return nil, 0, false
+	}
+	parent := hn
+	for _, token := range tokens[:len(tokens)-1] {
+		idx, ok := parent.index(token)
+		if !ok || idx >= len(parent.children) {
+			return nil, 0, false
+		}
+		parent = parent.children[idx]
+	}
+	idx, ok := parent.index(tokens[len(tokens)-1])
+	return parent, idx, ok
+}
+
+func (hn *htmlNode) index(token string) (int, bool) {
+	switch hn.node.Type {
+	case decode.JsonNodeTypeSlice:
+		if token == "-" {
+			return len(hn.children), true
+		}
+		i, err := strconv.Atoi(token)
+		return i, err == nil && i >= 0 && i <= len(hn.children)
+	case decode.JsonNodeTypeObject:
+		for i, child := range hn.children {
+			if child.key == token {
+				return i, true
+			}
+		}
+		return len(hn.children), true
+	}
+	return 0, false
+}
+
+func (hn *htmlNode) insert(idx int, child *htmlNode) {
+	if hn.node.Type == decode.JsonNodeTypeObject && idx < len(hn.children) {
+		hn.children[idx] = child
+		return
+	}
+	hn.children = append(hn.children, nil)
+	copy(hn.children[idx+1:], hn.children[idx:])
+	hn.children[idx] = child
+}
+
+func (hn *htmlNode) remove(idx int) *htmlNode {
+	child := hn.children[idx]
+	hn.children = append(hn.children[:idx], hn.children[idx+1:]...)
+	return child
+}
+
+// htmlReport 在源文档上依次模拟每一个差异，得到源文档和目标文档中每个节点的状态
+type htmlReport struct {
+	src, dst *htmlNode
+	ops      []*decode.JsonNode
+}
+
+func newHTMLReport(src, dst *decode.JsonNode, options []DiffOption) *htmlReport {
+	r := &htmlReport{ops: GetDiffNode(src, dst, options...).Children}
+	if src != nil {
+		r.src = newHTMLNode("", src)
+		r.dst = r.src.shadow()
+	}
+	for i, op := range r.ops {
+		r.apply(i, op)
+	}
+	return r
+}
+
+// apply 在目标树上模拟第 i 个差异，无法模拟的差异只出现在操作列表中
+func (r *htmlReport) apply(i int, op *decode.JsonNode) {
+	anchor := strconv.Itoa(i)
+	name, _ := diffString(op, "op")
+	path, err := diffString(op, "path")
+	if err != nil {
+		return
+	}
+	p, err := decode.ParsePointer(path)
+	if err != nil {
+		return
+	}
+	value, _ := diffValue(op)
+	if p.IsRoot() {
+		if value != nil && (name == "add" || name == "replace") {
+			if r.src != nil {
+				r.src.mark(htmlChanged, "src-op-"+anchor)
+			}
+			r.dst = newHTMLNode("", value)
+			r.dst.mark(htmlChanged, "dst-op-"+anchor)
+		}
+		return
+	}
+	if r.dst == nil {
+		return
+	}
+	parent, idx, ok := r.dst.locate(p.Tokens())
+	if !ok {
+		return
+	}
+	exists := idx < len(parent.children)
+	switch name {
+	case "add", "copy":
+		if name == "copy" {
+			value = r.find(op)
+		}
+		if value == nil {
+			return
+		}
+		child := newHTMLNode(p.Last(), value)
+		child.mark(htmlAdded, "dst-op-"+anchor)
+		parent.insert(idx, child)
+	case "remove":
+		if !exists {
+			return
+		}
+		child := parent.remove(idx)
+		if child.origin != nil {
+			child.origin.mark(htmlRemoved, "src-op-"+anchor)
+		}
+	case "replace":
+		if !exists || value == nil {
+			return
+		}
+		if old := parent.children[idx]; old.origin != nil {
+			old.origin.mark(htmlChanged, "src-op-"+anchor)
+		}
+		child := newHTMLNode(p.Last(), value)
+		child.mark(htmlChanged, "dst-op-"+anchor)
+		parent.children[idx] = child
+	case "move":
+		from, err := diffString(op, "from")
+		if err != nil {
+			return
+		}
+		fp, err := decode.ParsePointer(from)
+		if err != nil || fp.IsRoot() {
+			return
+		}
+		fromParent, fromIdx, ok := r.dst.locate(fp.Tokens())
+		if !ok || fromIdx >= len(fromParent.children) {
+			return
+		}
+		child := fromParent.remove(fromIdx)
+		parent, idx, ok = r.dst.locate(p.Tokens())
+		if !ok {
+			return
+		}
+		child.key = p.Last()
+		child.mark(htmlMoved, "dst-op-"+anchor)
+		if child.origin != nil {
+			child.origin.mark(htmlMoved, "src-op-"+anchor)
+		}
+		parent.insert(idx, child)
+	}
+}
+
+// find 返回 copy 操作中 from 处的节点
+func (r *htmlReport) find(op *decode.JsonNode) *decode.JsonNode {
+	from, err := diffString(op, "from")
+	if err != nil {
+		return nil
+	}
+	fp, err := decode.ParsePointer(from)
+	if err != nil {
+		return nil
+	}
+	if fp.IsRoot() {
+		return r.dst.node
+	}
+	parent, idx, ok := r.dst.locate(fp.Tokens())
+	if !ok || idx >= len(parent.children) {
+		return nil
+	}
+	return parent.children[idx].node
+}
+
+// RenderHTML 比较 src 和 dst，并把结果写入 w 生成一个不依赖任何外部资源的 HTML 页面：
+// 页面中包含 GetDiffNode 得到的差异列表，以及两个文档可折叠的树形视图，
+// 其中新增、删除、修改和移动的节点会被高亮，差异列表中的链接可以跳转到对应的节点。opts 可以为 nil
+func RenderHTML(w io.Writer, src, dst *decode.JsonNode, opts *HTMLOptions) error {
+	if opts == nil {
+		opts = &HTMLOptions{}
+	}
+	title := opts.Title
+	if title == "" {
+		title = "JSON Diff"
+	}
+	r := newHTMLReport(src, dst, opts.DiffOptions)
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n", html.EscapeString(title), htmlStyle)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(title))
+	if err := r.writeOps(&b); err != nil {
+		return err
+	}
+	b.WriteString("<div class=\"trees\">\n<section>\n<h2>Source</h2>\n")
+	writeHTMLTree(&b, r.src, "")
+	b.WriteString("</section>\n<section>\n<h2>Target</h2>\n")
+	writeHTMLTree(&b, r.dst, "")
+	b.WriteString("</section>\n</div>\n</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return errors.WithStack(err)
+}
+
+func (r *htmlReport) writeOps(b *strings.Builder) error {
+	fmt.Fprintf(b, "<section class=\"ops\">\n<h2>Operations (%d)</h2>\n", len(r.ops))
+	if len(r.ops) == 0 {
+		b.WriteString("<p>No differences.</p>\n</section>\n")
+		return nil
+	}
+	b.WriteString("<ol start=\"0\">\n")
+	for i, op := range r.ops {
+		name, _ := diffString(op, "op")
+		path, _ := diffString(op, "path")
+		fmt.Fprintf(b, "<li id=\"op-%d\"><span class=\"op op-%s\">%s</span> <code>%s</code>",
+			i, html.EscapeString(name), html.EscapeString(name), html.EscapeString(path))
+		if from, err := diffString(op, "from"); err == nil {
+			fmt.Fprintf(b, " from <code>%s</code>", html.EscapeString(from))
+		}
+		if value, ok := op.ChildrenMap["value"]; ok {
+			v, err := decode.Marshal(value)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			fmt.Fprintf(b, " <code class=\"value\">%s</code>", html.EscapeString(string(v)))
+		}
+		if r.src != nil && r.src.hasAnchor("src-op-"+strconv.Itoa(i)) {
+			fmt.Fprintf(b, " <a href=\"#src-op-%d\">source</a>", i)
+		}
+		if r.dst != nil && r.dst.hasAnchor("dst-op-"+strconv.Itoa(i)) {
+			fmt.Fprintf(b, " <a href=\"#dst-op-%d\">target</a>", i)
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ol>\n</section>\n")
+	return nil
+}
+
+func (hn *htmlNode) hasAnchor(anchor string) bool {
+	for _, a := range hn.anchors {
+		if a == anchor {
+			return true
+		}
+	}
+	for _, child := range hn.children {
+		if child.hasAnchor(anchor) {
+			return true
+		}
+	}
+	return false
+}
+
+// changed 判断 hn 或它的子节点是否有变化，没有变化的容器默认折叠
+func (hn *htmlNode) changed() bool {
+	if hn.status != "" {
+		return true
+	}
+	for _, child := range hn.children {
+		if child.changed() {
+			return true
+		}
+	}
+	return false
+}
+
+func writeHTMLTree(b *strings.Builder, hn *htmlNode, label string) {
+	if hn == nil {
+		b.WriteString("<p class=\"empty\">(empty)</p>\n")
+		return
+	}
+	class := "node"
+	if hn.status != "" {
+		class += " " + hn.status
+	}
+	anchors := ""
+	for _, a := range hn.anchors {
+		anchors += fmt.Sprintf("<span id=\"%s\"></span>", a)
+	}
+	if label != "" {
+		label = "<span class=\"key\">" + html.EscapeString(label) + "</span>: "
+	}
+	if hn.node.Type == decode.JsonNodeTypeValue {
+		v, err := decode.Marshal(hn.node)
+		if err != nil {
+			v = []byte(fmt.Sprintf("%v", hn.node.Value))
+		}
+		fmt.Fprintf(b, "<div class=\"%s\">%s%s<span class=\"value\">%s</span></div>\n",
+			class, anchors, label, html.EscapeString(string(v)))
+		return
+	}
+	open, end := "{", "}"
+	if hn.node.Type == decode.JsonNodeTypeSlice {
+		open, end = "[", "]"
+	}
+	attr := ""
+	if hn.changed() {
+		attr = " open"
+	}
+	fmt.Fprintf(b, "<details class=\"%s\"%s><summary>%s%s%s <span class=\"count\">%d</span></summary>\n",
+		class, attr, anchors, label, open, len(hn.children))
+	for i, child := range hn.children {
+		key := strconv.Itoa(i)
+		if hn.node.Type == decode.JsonNodeTypeObject {
+			key = strconv.Quote(child.key)
+		}
+		writeHTMLTree(b, child, key)
+	}
+	fmt.Fprintf(b, "<div class=\"end\">%s</div>\n</details>\n", end)
+}
+
+const htmlStyle = `
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292e; }
+code, .trees { font-family: SFMono-Regular, Consolas, Menlo, monospace; font-size: 13px; }
+.ops li { margin: 4px 0; }
+.op { display: inline-block; min-width: 5em; font-weight: bold; }
+.op-add, .op-copy { color: #22863a; }
+.op-remove { color: #cb2431; }
+.op-replace { color: #b08800; }
+.op-move { color: #005cc5; }
+.trees { display: flex; gap: 2em; }
+.trees section { flex: 1; min-width: 0; overflow-x: auto; }
+details, div.node { margin-left: 1.5em; }
+.trees > section > details, .trees > section > div.node { margin-left: 0; }
+summary { cursor: pointer; }
+.count { color: #6a737d; font-size: 11px; }
+.key { color: #6f42c1; }
+.added { background: #e6ffed; }
+.removed { background: #ffeef0; text-decoration: line-through; }
+.changed { background: #fff5b1; }
+.moved { background: #dbedff; }
+`
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"bytes"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	src, _ := decode.Unmarshal([]byte(`{"name": "app", "version": 1, "list": [{"id": 1}, {"id": 2}, {"id": 3}], "old": true, "same": {"a": 1}}`))
+	dst, _ := decode.Unmarshal([]byte(`{"name": "app", "version": 2, "list": [{"id": 3}, {"id": 1}, {"id": 2, "x": 1}], "new": "<b>", "same": {"a": 1}}`))
+	var buf bytes.Buffer
+	err := RenderHTML(&buf, src, dst, &HTMLOptions{
+		Title:       "release <1.2>",
+		DiffOptions: []DiffOption{UseArrayKeyOption("/list/*", "id")},
+	})
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`<title>release &lt;1.2&gt;</title>`,
+		`<h2>Operations (5)</h2>`,
+		`<li id="op-0"><span class="op op-replace">replace</span> <code>/version</code> <code class="value">2</code> <a href="#src-op-0">source</a> <a href="#dst-op-0">target</a></li>`,
+		`<li id="op-1"><span class="op op-move">move</span> <code>/list/0</code> from <code>/list/2</code> <a href="#src-op-1">source</a> <a href="#dst-op-1">target</a></li>`,
+		`<li id="op-3"><span class="op op-remove">remove</span> <code>/old</code> <a href="#src-op-3">source</a></li>`,
+		`<div class="node changed"><span id="src-op-0"></span><span class="key">&#34;version&#34;</span>: <span class="value">1</span></div>`,
+		`<div class="node changed"><span id="dst-op-0"></span><span class="key">&#34;version&#34;</span>: <span class="value">2</span></div>`,
+		`<details class="node moved" open><summary><span id="src-op-1"></span><span class="key">2</span>: { <span class="count">1</span></summary>`,
+		`<details class="node moved" open><summary><span id="dst-op-1"></span><span class="key">0</span>: { <span class="count">1</span></summary>`,
+		`<div class="node added"><span id="dst-op-2"></span><span class="key">&#34;x&#34;</span>: <span class="value">1</span></div>`,
+		`<div class="node removed"><span id="src-op-3"></span><span class="key">&#34;old&#34;</span>: <span class="value">true</span></div>`,
+		`<div class="node added"><span id="dst-op-4"></span><span class="key">&#34;new&#34;</span>: <span class="value">&#34;&lt;b&gt;&#34;</span></div>`,
+		// 没有变化的容器默认折叠
+		`<details class="node"><summary><span class="key">&#34;same&#34;</span>: { <span class="count">1</span></summary>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("want %s in the report", want)
+		}
+	}
+	for _, external := range []string{"<script src", "<link", "http://", "https://"} {
+		if strings.Contains(got, external) {
+			t.Errorf("the report should not refer to external assets: %s", external)
+		}
+	}
+}
+
+func TestRenderHTML_special(t *testing.T) {
+	node, _ := decode.Unmarshal([]byte(`{"a": [1, 2]}`))
+	var buf bytes.Buffer
+	if err := RenderHTML(&buf, node, node, nil); err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "<p>No differences.</p>") {
+		t.Errorf("want no differences, got %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := RenderHTML(&buf, nil, node, nil); err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	for _, want := range []string{
+		`<p class="empty">(empty)</p>`,
+		`<details class="node changed" open><summary><span id="dst-op-0"></span>{ <span class="count">1</span></summary>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("want %s in the report", want)
+		}
+	}
+}
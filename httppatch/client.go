@@ -0,0 +1,177 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package httppatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	jsondiff "github.com/520MianXiangDuiXiang520/json-diff"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// defaultMaxRetries 是 Client.MaxRetries 小于等于 0 时使用的重试次数
+const defaultMaxRetries = 3
+
+// RebaseFunc 在服务端返回 412（资源在读取后已被修改）时调用，根据本地修改前的 original、修改后的 edited
+// 和重新读取到的 current 返回新的修改结果，Client 会计算 current 到该结果的差异并重试。返回 error 时放弃重试
+type RebaseFunc func(original, edited, current *decode.JsonNode) (*decode.JsonNode, error)
+
+// Merge3Rebase 是使用 json_diff.Merge3 实现的 RebaseFunc：以 original 为共同祖先，把本地的修改合并到 current 上，
+// 有冲突时返回由 json_diff.ErrMergeConflict 装饰的 error
+func Merge3Rebase(original, edited, current *decode.JsonNode) (*decode.JsonNode, error) {
+	res, _, err := jsondiff.Merge3(original, current, edited, nil)
+	return res, err
+}
+
+// Client 读取资源并以 JSON Patch 的形式发送本地的修改，使用 If-Match 实现乐观并发控制
+type Client struct {
+	// HTTPClient 发送请求使用的 http.Client，为 nil 时使用 http.DefaultClient
+	HTTPClient *http.Client
+	// DiffOptions 计算差异时使用的选项
+	DiffOptions []jsondiff.DiffOption
+	// TestGuards 为 true 时在每一个 replace、remove 和 move 操作之前添加 test 操作，见 json_diff.UseTestGuardOption
+	TestGuards bool
+	// Rebase 为 nil 时服务端返回 412 不会重试
+	Rebase RebaseFunc
+	// MaxRetries 服务端返回 412 后最多重试的次数，小于等于 0 时使用 3
+	MaxRetries int
+}
+
+// NewClient 创建一个使用 rebase 处理并发修改的 Client
+func NewClient(rebase RebaseFunc) *Client {
+	return &Client{Rebase: rebase}
+}
+
+// Fetch 使用 GET 读取 url 处的资源，返回资源和响应中的 ETag。
+// 服务端返回的状态码不是 2xx 时返回 *Problem
+func (c *Client) Fetch(ctx context.Context, url string) (*decode.JsonNode, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "application/json")
+	return c.do(req)
+}
+
+// Patch 使用 GetDiffNode 计算 original 到 edited 的差异，并以 application/json-patch+json 发送到 url，
+// etag 不为空时放在 If-Match 中；没有差异时不会发送请求。成功时返回服务端修改后的资源和新的 ETag。
+// 服务端返回 412 并且设置了 Rebase 时，Patch 会重新读取资源，通过 Rebase 得到新的修改结果后重试，
+// 重试次数用完后返回的 error 可以使用 errors.Is(err, ErrPreconditionFailed) 判断。
+// 服务端返回其他错误时返回 *Problem，可以使用 errors.As 获取
+func (c *Client) Patch(ctx context.Context, url string, original, edited *decode.JsonNode, etag string) (*decode.JsonNode, string, error) {
+	options := c.DiffOptions
+	if c.TestGuards {
+		options = append(options[:len(options):len(options)], jsondiff.UseTestGuardOption)
+	}
+	maxRetries := c.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	for retries := 0; ; retries++ {
+		patch := jsondiff.GetDiffNode(original, edited, options...)
+		if len(patch.Children) == 0 {
+			return original, etag, nil
+		}
+		doc, newETag, err := c.send(ctx, url, patch, etag)
+		if err == nil && doc == nil {
+			// 服务端没有返回修改后的资源，如 204
+			doc = edited
+		}
+		if !errors.Is(err, ErrPreconditionFailed) || c.Rebase == nil {
+			return doc, newETag, err
+		}
+		if retries >= maxRetries {
+			return nil, "", errors.Wrapf(err, "gave up after %d retries", retries)
+		}
+		current, currentETag, err := c.Fetch(ctx, url)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "fail to fetch the resource")
+		}
+		if edited, err = c.Rebase(original, edited, current); err != nil {
+			return nil, "", errors.Wrap(err, "fail to rebase")
+		}
+		original, etag = current, currentETag
+	}
+}
+
+func (c *Client) send(ctx context.Context, url string, patch *decode.JsonNode, etag string) (*decode.JsonNode, string, error) {
+	data, err := decode.Marshal(patch)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "fail to marshal the patch")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", MediaTypeJSONPatch)
+	req.Header.Set("Accept", "application/json")
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	return c.do(req)
+}
+
+// do 发送请求并解析响应中的 JSON 文档，响应没有内容时返回的文档为 nil
+func (c *Client) do(req *http.Request) (*decode.JsonNode, string, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "fail to read the response body")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, "", errors.WithStack(readProblem(resp, data))
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, resp.Header.Get("ETag"), nil
+	}
+	doc, err := decode.Unmarshal(data)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "fail to unmarshal the response body")
+	}
+	return doc, resp.Header.Get("ETag"), nil
+}
+
+// readProblem 把错误响应转换为 *Problem，响应不是 application/problem+json 时使用状态码和响应体构造
+func readProblem(resp *http.Response, data []byte) *Problem {
+	p := &Problem{}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != MediaTypeProblem || json.Unmarshal(data, p) != nil {
+		p = &Problem{Type: "about:blank", Detail: strings.TrimSpace(string(data))}
+	}
+	if p.Status == 0 {
+		p.Status = resp.StatusCode
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(resp.StatusCode)
+	}
+	return p
+}
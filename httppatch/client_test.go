@@ -0,0 +1,251 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package httppatch
+
+import (
+	"bytes"
+	"context"
+	jsondiff "github.com/520MianXiangDuiXiang520/json-diff"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// testServer 使用 memStore 和 Handler 提供 GET 和 PATCH，记录收到的 PATCH 请求体，
+// beforePatch 不为 nil 时在处理每个 PATCH 请求之前调用，用于模拟并发的修改
+type testServer struct {
+	*httptest.Server
+	store       *memStore
+	beforePatch func()
+
+	mu      sync.Mutex
+	patches []string
+}
+
+func newTestServer(t *testing.T, doc string) *testServer {
+	s := &testServer{store: newMemStore(t, doc)}
+	handler := NewHandler(s.store.load, s.store.store)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			doc, _ := s.store.load(r)
+			etag, _ := ETag(doc)
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(s.store.String()))
+			return
+		}
+		if s.beforePatch != nil {
+			s.beforePatch()
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		s.mu.Lock()
+		s.patches = append(s.patches, string(body))
+		s.mu.Unlock()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		handler.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// set 直接修改服务端保存的资源
+func (s *testServer) set(t *testing.T, doc string) {
+	node, err := decode.Unmarshal([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.store.mu.Lock()
+	s.store.doc = node
+	s.store.mu.Unlock()
+}
+
+func mustNode(t *testing.T, doc string) *decode.JsonNode {
+	node, err := decode.Unmarshal([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+func TestClient_Patch(t *testing.T) {
+	s := newTestServer(t, `{"name":"json-diff","tags":["json"],"version":1}`)
+	defer s.Close()
+	c := NewClient(nil)
+	original, etag, err := c.Fetch(context.Background(), s.URL)
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	edited := mustNode(t, `{"name":"json-diff","tags":["json","patch"],"version":2}`)
+	doc, newETag, err := c.Patch(context.Background(), s.URL, original, edited, etag)
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	want := `{"name":"json-diff","tags":["json","patch"],"version":2}`
+	if node2string(doc) != want || s.store.String() != want {
+		t.Errorf("want %s, got %s, stored %s", want, node2string(doc), s.store)
+	}
+	if newETag != mustETag(t, want) {
+		t.Errorf("want etag %s, got %s", mustETag(t, want), newETag)
+	}
+	if len(s.patches) != 1 || s.patches[0] != `[{"op":"add","path":"/tags/1","value":"patch"},{"op":"replace","path":"/version","value":2}]` {
+		t.Errorf("unexpected patches %v", s.patches)
+	}
+
+	// 没有修改时不发送请求
+	if _, _, err := c.Patch(context.Background(), s.URL, doc, doc, newETag); err != nil || len(s.patches) != 1 {
+		t.Errorf("want no request, got %v, %v", err, s.patches)
+	}
+}
+
+func TestClient_testGuards(t *testing.T) {
+	s := newTestServer(t, `{"name":"json-diff","version":1}`)
+	defer s.Close()
+	c := &Client{TestGuards: true}
+	original := mustNode(t, `{"name":"json-diff","version":1}`)
+	edited := mustNode(t, `{"name":"json-diff","version":2}`)
+	s.set(t, `{"name":"json-diff","version":3}`)
+	// 不使用 If-Match 时由 test 操作发现资源已被修改
+	_, _, err := c.Patch(context.Background(), s.URL, original, edited, "")
+	var p *Problem
+	if !errors.As(err, &p) || p.Status != http.StatusConflict || p.Op != "test" || p.Path != "/version" {
+		t.Fatalf("want a 409 problem for the test operation, got %v", err)
+	}
+	if want := `[{"op":"test","path":"/version","value":1},{"op":"replace","path":"/version","value":2}]`; s.patches[0] != want {
+		t.Errorf("want patch %s, got %s", want, s.patches[0])
+	}
+}
+
+func TestClient_rebase(t *testing.T) {
+	tests := []struct {
+		name       string
+		rebase     RebaseFunc
+		concurrent string
+		// edited 为空时只修改 version
+		edited    string
+		want      string
+		wantErr   error
+		wantCount int
+	}{
+		{
+			name:       "merge",
+			rebase:     Merge3Rebase,
+			concurrent: `{"name":"json-diff","tags":["json"],"version":1,"license":"Apache-2.0"}`,
+			want:       `{"name":"json-diff","tags":["json"],"version":2,"license":"Apache-2.0"}`,
+			wantCount:  2,
+		},
+		{
+			name:       "append to an array",
+			rebase:     Merge3Rebase,
+			concurrent: `{"name":"json-diff","tags":["json","diff"],"version":1}`,
+			edited:     `{"name":"json-diff","tags":["JSON"],"version":2}`,
+			want:       `{"name":"json-diff","tags":["JSON","diff"],"version":2}`,
+			wantCount:  2,
+		},
+		{
+			name:       "conflict",
+			rebase:     Merge3Rebase,
+			concurrent: `{"name":"json-diff","tags":["json"],"version":3}`,
+			want:       `{"name":"json-diff","tags":["json"],"version":3}`,
+			wantErr:    jsondiff.ErrMergeConflict,
+			wantCount:  1,
+		},
+		{
+			name:       "no rebase",
+			concurrent: `{"name":"json-diff","tags":["json"],"version":1,"license":"Apache-2.0"}`,
+			want:       `{"name":"json-diff","tags":["json"],"version":1,"license":"Apache-2.0"}`,
+			wantErr:    ErrPreconditionFailed,
+			wantCount:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const doc = `{"name":"json-diff","tags":["json"],"version":1}`
+			s := newTestServer(t, doc)
+			defer s.Close()
+			// 在第一个 PATCH 请求之前修改资源
+			s.beforePatch = func() {
+				s.beforePatch = nil
+				s.set(t, tt.concurrent)
+			}
+			c := NewClient(tt.rebase)
+			original := mustNode(t, doc)
+			if tt.edited == "" {
+				tt.edited = `{"name":"json-diff","tags":["json"],"version":2}`
+			}
+			edited := mustNode(t, tt.edited)
+			got, _, err := c.Patch(context.Background(), s.URL, original, edited, mustETag(t, doc))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("want %v, got %v", tt.wantErr, err)
+				}
+			} else if err != nil || node2string(got) != tt.want {
+				t.Errorf("want %s, got %s, %v", tt.want, node2string(got), err)
+			}
+			if s.store.String() != tt.want {
+				t.Errorf("want stored %s, got %s", tt.want, s.store)
+			}
+			if len(s.patches) != tt.wantCount {
+				t.Errorf("want %d requests, got %v", tt.wantCount, s.patches)
+			}
+		})
+	}
+}
+
+func TestClient_maxRetries(t *testing.T) {
+	s := newTestServer(t, `{"version":1}`)
+	defer s.Close()
+	// 每次 PATCH 之前资源都被修改
+	version := 1
+	s.beforePatch = func() {
+		version++
+		s.set(t, `{"version":1,"other":`+strconv.Itoa(version)+`}`)
+	}
+	rebased := 0
+	c := NewClient(func(original, edited, current *decode.JsonNode) (*decode.JsonNode, error) {
+		rebased++
+		return Merge3Rebase(original, edited, current)
+	})
+	c.MaxRetries = 2
+	_, _, err := c.Patch(context.Background(), s.URL, mustNode(t, `{"version":1}`), mustNode(t, `{"version":2}`), mustETag(t, `{"version":1}`))
+	var p *Problem
+	if !errors.Is(err, ErrPreconditionFailed) || !errors.As(err, &p) || p.Status != http.StatusPreconditionFailed {
+		t.Errorf("want a 412 problem, got %v", err)
+	}
+	if len(s.patches) != 3 || rebased != 2 {
+		t.Errorf("want 3 requests and 2 rebases, got %d and %d", len(s.patches), rebased)
+	}
+}
+
+func TestClient_notFound(t *testing.T) {
+	s := httptest.NewServer(http.NotFoundHandler())
+	defer s.Close()
+	_, _, err := NewClient(nil).Fetch(context.Background(), s.URL)
+	var p *Problem
+	if !errors.Is(err, ErrNotFound) || !errors.As(err, &p) || p.Detail != "404 page not found" {
+		t.Errorf("want a 404 problem, got %v", err)
+	}
+}
+
+func node2string(node *decode.JsonNode) string {
+	data, _ := decode.Marshal(node)
+	return string(data)
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package httppatch
+
+import (
+	"bytes"
+	jsondiff "github.com/520MianXiangDuiXiang520/json-diff"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+// LoadFunc 读取请求对应的资源，资源不存在时应当返回 ErrNotFound
+type LoadFunc func(r *http.Request) (*decode.JsonNode, error)
+
+// StoreFunc 保存修改后的资源 doc，etag 是 LoadFunc 读取到的资源的 ETag。
+// 为了避免并发的请求互相覆盖，StoreFunc 可以在保存时检查资源的 ETag 是否仍然是 etag，不是时返回 ErrPreconditionFailed
+type StoreFunc func(r *http.Request, doc *decode.JsonNode, etag string) error
+
+// Handler 处理 PATCH 请求：根据 Content-Type 选择 JSON Patch 或 JSON Merge Patch，检查 If-Match，
+// 把差异应用于 Load 读取的资源，再通过 Store 保存，成功时返回 200、修改后的资源以及新的 ETag。
+// 差异的应用是原子性的，任何一个操作失败都不会调用 Store。出错时以 application/problem+json 格式返回：
+//
+//	400 请求体不是合法的 JSON 或差异不是数组
+//	404 Load 返回 ErrNotFound
+//	409 差异与资源当前的状态冲突：路径不存在、数组下标越界或 test 失败
+//	412 If-Match 与资源的 ETag 不匹配，或 Store 返回 ErrPreconditionFailed
+//	413 请求体超过 MaxBodyBytes
+//	415 Content-Type 不是 application/json-patch+json 或 application/merge-patch+json
+//	422 差异中有不合法的操作
+//	428 RequireIfMatch 为 true 但请求中没有 If-Match
+type Handler struct {
+	Load  LoadFunc
+	Store StoreFunc
+	// Options 应用 JSON Patch 时使用的选项，如 jsondiff.UseLenientMergeOption
+	Options []jsondiff.DiffOption
+	// RequireIfMatch 为 true 时拒绝没有 If-Match 的请求
+	RequireIfMatch bool
+	// MaxBodyBytes 请求体的最大字节数，小于等于 0 时不限制
+	MaxBodyBytes int64
+}
+
+// NewHandler 使用 load 和 store 创建一个 Handler
+func NewHandler(load LoadFunc, store StoreFunc) *Handler {
+	return &Handler{Load: load, Store: store}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		w.Header().Set("Allow", http.MethodPatch)
+		writeProblem(w, newProblem(http.StatusMethodNotAllowed, errors.Errorf("method %s is not allowed", r.Method)))
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != MediaTypeJSONPatch && mediaType != MediaTypeMergePatch {
+		w.Header().Set("Accept-Patch", MediaTypeJSONPatch+", "+MediaTypeMergePatch)
+		writeProblem(w, newProblem(http.StatusUnsupportedMediaType,
+			errors.Errorf("unsupported content type %q", r.Header.Get("Content-Type"))))
+		return
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" && h.RequireIfMatch {
+		writeProblem(w, newProblem(http.StatusPreconditionRequired, errors.New("the request must contain If-Match")))
+		return
+	}
+
+	doc, err := h.Load(r)
+	if errors.Is(err, ErrNotFound) {
+		writeProblem(w, newProblem(http.StatusNotFound, err))
+		return
+	}
+	if err != nil {
+		writeProblem(w, newProblem(http.StatusInternalServerError, errors.New("fail to load the resource")))
+		return
+	}
+	etag, err := ETag(doc)
+	if err != nil {
+		writeProblem(w, newProblem(http.StatusInternalServerError, err))
+		return
+	}
+	if ifMatch != "" && !matchETag(ifMatch, etag) {
+		w.Header().Set("ETag", etag)
+		writeProblem(w, newProblem(http.StatusPreconditionFailed, errors.Errorf("the resource does not match %s", ifMatch)))
+		return
+	}
+
+	patch, problem := h.readPatch(r)
+	if problem != nil {
+		writeProblem(w, problem)
+		return
+	}
+	var res *decode.JsonNode
+	if mediaType == MediaTypeMergePatch {
+		res, err = jsondiff.ApplyMergePatchNode(doc, patch)
+	} else {
+		res, err = jsondiff.MergeDiffNode(doc, patch, h.Options...)
+	}
+	if err != nil {
+		writeProblem(w, patchProblem(err))
+		return
+	}
+
+	err = h.Store(r, res, etag)
+	if errors.Is(err, ErrPreconditionFailed) {
+		writeProblem(w, newProblem(http.StatusPreconditionFailed, err))
+		return
+	}
+	if err != nil {
+		writeProblem(w, newProblem(http.StatusInternalServerError, errors.New("fail to store the resource")))
+		return
+	}
+	data, err := decode.Marshal(res)
+	if err != nil {
+		writeProblem(w, newProblem(http.StatusInternalServerError, err))
+		return
+	}
+	newETag, err := ETag(res)
+	if err != nil {
+		writeProblem(w, newProblem(http.StatusInternalServerError, err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", newETag)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// readPatch 读取并解析请求体
+func (h *Handler) readPatch(r *http.Request) (*decode.JsonNode, *Problem) {
+	var body io.Reader = r.Body
+	if h.MaxBodyBytes > 0 {
+		body = io.LimitReader(r.Body, h.MaxBodyBytes+1)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, newProblem(http.StatusBadRequest, errors.Wrap(err, "fail to read the request body"))
+	}
+	if h.MaxBodyBytes > 0 && int64(len(data)) > h.MaxBodyBytes {
+		return nil, newProblem(http.StatusRequestEntityTooLarge,
+			errors.Errorf("the request body is larger than %d bytes", h.MaxBodyBytes))
+	}
+	data = bytes.TrimSpace(data)
+	var patch *decode.JsonNode
+	if len(data) > 0 {
+		patch, err = decode.Unmarshal(data)
+		if err != nil {
+			return nil, newProblem(http.StatusBadRequest, err)
+		}
+	}
+	if patch == nil {
+		return nil, newProblem(http.StatusBadRequest, errors.New("the patch document is empty"))
+	}
+	return patch, nil
+}
+
+// patchProblem 根据应用差异失败的原因选择响应的状态码
+func patchProblem(err error) *Problem {
+	var pe *jsondiff.PatchError
+	if !errors.As(err, &pe) {
+		if errors.Is(err, decode.BadDiffsError) {
+			return newProblem(http.StatusBadRequest, err)
+		}
+		return newProblem(http.StatusUnprocessableEntity, err)
+	}
+	status := http.StatusConflict
+	if errors.Is(err, jsondiff.ErrInvalidOperation) {
+		status = http.StatusUnprocessableEntity
+	}
+	p := newProblem(status, pe)
+	p.Index = &pe.Index
+	p.Op, p.Path, p.From = pe.Op, pe.Path, pe.From
+	return p
+}
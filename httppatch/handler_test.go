@@ -0,0 +1,335 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package httppatch
+
+import (
+	"encoding/json"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memStore 是保存在内存中的资源，保存时检查 ETag
+type memStore struct {
+	mu     sync.Mutex
+	doc    *decode.JsonNode
+	stores int
+}
+
+func newMemStore(t *testing.T, doc string) *memStore {
+	node, err := decode.Unmarshal([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &memStore{doc: node}
+}
+
+func (s *memStore) load(r *http.Request) (*decode.JsonNode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.doc == nil {
+		return nil, ErrNotFound
+	}
+	return s.doc, nil
+}
+
+func (s *memStore) store(r *http.Request, doc *decode.JsonNode, etag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, err := ETag(s.doc)
+	if err != nil {
+		return err
+	}
+	if current != etag {
+		return ErrPreconditionFailed
+	}
+	s.doc = doc
+	s.stores++
+	return nil
+}
+
+func (s *memStore) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, _ := decode.Marshal(s.doc)
+	return string(data)
+}
+
+func mustETag(t *testing.T, doc string) string {
+	node, err := decode.Unmarshal([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag, err := ETag(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return etag
+}
+
+func TestETag(t *testing.T) {
+	a := mustETag(t, `{"a": 1, "b": [1, "中"]}`)
+	b := mustETag(t, `{ "b": [1, "中"], "a": 1 }`)
+	c := mustETag(t, `{"a": 1, "b": [1, "中", 2]}`)
+	if a != b {
+		t.Errorf("want the same etag, got %s and %s", a, b)
+	}
+	if a == c {
+		t.Errorf("want different etags, got %s", a)
+	}
+	if len(a) != 66 || a[0] != '"' || a[65] != '"' {
+		t.Errorf("%s is not a quoted sha256", a)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	const doc = `{"name":"json-diff","tags":["json"],"version":1}`
+	etag := mustETag(t, doc)
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		ifMatch     string
+		body        string
+		handler     func(h *Handler)
+		wantStatus  int
+		want        string
+		// wantProblem 是错误响应中除 detail 以外的字段
+		wantProblem string
+	}{
+		{
+			name:        "json patch",
+			contentType: MediaTypeJSONPatch,
+			body:        `[{"op": "replace", "path": "/version", "value": 2}, {"op": "add", "path": "/tags/-", "value": "patch"}]`,
+			wantStatus:  http.StatusOK,
+			want:        `{"name":"json-diff","tags":["json","patch"],"version":2}`,
+		},
+		{
+			name:        "merge patch",
+			contentType: MediaTypeMergePatch + "; charset=utf-8",
+			ifMatch:     etag,
+			body:        `{"version": 2, "tags": null}`,
+			wantStatus:  http.StatusOK,
+			want:        `{"name":"json-diff","version":2}`,
+		},
+		{
+			name:        "if-match list",
+			contentType: MediaTypeJSONPatch,
+			ifMatch:     `"other", ` + etag,
+			body:        `[]`,
+			wantStatus:  http.StatusOK,
+			want:        doc,
+		},
+		{
+			name:        "if-match any",
+			contentType: MediaTypeJSONPatch,
+			ifMatch:     "*",
+			body:        `[]`,
+			wantStatus:  http.StatusOK,
+			want:        doc,
+		},
+		{
+			name:        "if-match mismatch",
+			contentType: MediaTypeJSONPatch,
+			ifMatch:     `"other"`,
+			body:        `[]`,
+			wantStatus:  http.StatusPreconditionFailed,
+			wantProblem: `{"type":"about:blank","title":"Precondition Failed","status":412}`,
+		},
+		{
+			name:        "weak if-match",
+			contentType: MediaTypeJSONPatch,
+			ifMatch:     "W/" + etag,
+			body:        `[]`,
+			wantStatus:  http.StatusPreconditionFailed,
+			wantProblem: `{"type":"about:blank","title":"Precondition Failed","status":412}`,
+		},
+		{
+			name:        "if-match required",
+			contentType: MediaTypeJSONPatch,
+			body:        `[]`,
+			handler:     func(h *Handler) { h.RequireIfMatch = true },
+			wantStatus:  http.StatusPreconditionRequired,
+			wantProblem: `{"type":"about:blank","title":"Precondition Required","status":428}`,
+		},
+		{
+			name:        "method",
+			method:      http.MethodPut,
+			contentType: MediaTypeJSONPatch,
+			body:        `[]`,
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantProblem: `{"type":"about:blank","title":"Method Not Allowed","status":405}`,
+		},
+		{
+			name:        "content type",
+			contentType: "application/json",
+			body:        `[]`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantProblem: `{"type":"about:blank","title":"Unsupported Media Type","status":415}`,
+		},
+		{
+			name:        "syntax error",
+			contentType: MediaTypeJSONPatch,
+			body:        `[{"op": "remove",`,
+			wantStatus:  http.StatusBadRequest,
+			wantProblem: `{"type":"about:blank","title":"Bad Request","status":400}`,
+		},
+		{
+			name:        "empty json patch",
+			contentType: MediaTypeJSONPatch,
+			body:        " \n",
+			wantStatus:  http.StatusBadRequest,
+			wantProblem: `{"type":"about:blank","title":"Bad Request","status":400}`,
+		},
+		{
+			name:        "empty merge patch",
+			contentType: MediaTypeMergePatch,
+			body:        "",
+			wantStatus:  http.StatusBadRequest,
+			wantProblem: `{"type":"about:blank","title":"Bad Request","status":400}`,
+		},
+		{
+			name:        "not an array",
+			contentType: MediaTypeJSONPatch,
+			body:        `{"op": "remove", "path": "/name"}`,
+			wantStatus:  http.StatusBadRequest,
+			wantProblem: `{"type":"about:blank","title":"Bad Request","status":400}`,
+		},
+		{
+			name:        "path not found",
+			contentType: MediaTypeJSONPatch,
+			body:        `[{"op": "replace", "path": "/version", "value": 2}, {"op": "remove", "path": "/owner"}]`,
+			wantStatus:  http.StatusConflict,
+			wantProblem: `{"type":"about:blank","title":"Conflict","status":409,"index":1,"op":"remove","path":"/owner"}`,
+		},
+		{
+			name:        "test failed",
+			contentType: MediaTypeJSONPatch,
+			body:        `[{"op": "test", "path": "/version", "value": 2}]`,
+			wantStatus:  http.StatusConflict,
+			wantProblem: `{"type":"about:blank","title":"Conflict","status":409,"index":0,"op":"test","path":"/version"}`,
+		},
+		{
+			name:        "index out of range",
+			contentType: MediaTypeJSONPatch,
+			body:        `[{"op": "move", "from": "/tags/3", "path": "/tag"}]`,
+			wantStatus:  http.StatusConflict,
+			wantProblem: `{"type":"about:blank","title":"Conflict","status":409,"index":0,"op":"move","path":"/tag","from":"/tags/3"}`,
+		},
+		{
+			name:        "invalid operation",
+			contentType: MediaTypeJSONPatch,
+			body:        `[{"op": "rename", "path": "/name"}]`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantProblem: `{"type":"about:blank","title":"Unprocessable Entity","status":422,"index":0,"op":"rename","path":"/name"}`,
+		},
+		{
+			name:        "too large",
+			contentType: MediaTypeJSONPatch,
+			body:        `[{"op": "remove", "path": "/name"}]`,
+			handler:     func(h *Handler) { h.MaxBodyBytes = 16 },
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantProblem: `{"type":"about:blank","title":"Request Entity Too Large","status":413}`,
+		},
+		{
+			name:        "not found",
+			contentType: MediaTypeJSONPatch,
+			body:        `[]`,
+			handler: func(h *Handler) {
+				h.Load = func(r *http.Request) (*decode.JsonNode, error) { return nil, errors.WithStack(ErrNotFound) }
+			},
+			wantStatus:  http.StatusNotFound,
+			wantProblem: `{"type":"about:blank","title":"Not Found","status":404}`,
+		},
+		{
+			name:        "modified concurrently",
+			contentType: MediaTypeJSONPatch,
+			body:        `[]`,
+			handler: func(h *Handler) {
+				h.Store = func(r *http.Request, doc *decode.JsonNode, etag string) error { return ErrPreconditionFailed }
+			},
+			wantStatus:  http.StatusPreconditionFailed,
+			wantProblem: `{"type":"about:blank","title":"Precondition Failed","status":412}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMemStore(t, doc)
+			h := NewHandler(s.load, s.store)
+			if tt.handler != nil {
+				tt.handler(h)
+			}
+			method := tt.method
+			if method == "" {
+				method = http.MethodPatch
+			}
+			r := httptest.NewRequest(method, "/resource", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if ct := w.Header().Get("Content-Type"); ct != MediaTypeProblem {
+					t.Errorf("want content type %s, got %s", MediaTypeProblem, ct)
+				}
+				var p Problem
+				if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+					t.Fatalf("fail to unmarshal %s: %v", w.Body.String(), err)
+				}
+				if p.Detail == "" {
+					t.Errorf("want detail in %s", w.Body.String())
+				}
+				p.Detail = ""
+				got, _ := json.Marshal(p)
+				if string(got) != tt.wantProblem {
+					t.Errorf("want problem %s, got %s", tt.wantProblem, got)
+				}
+				if s.stores != 0 || s.String() != doc {
+					t.Errorf("the resource was modified: %s", s)
+				}
+				return
+			}
+			if w.Body.String() != tt.want || s.String() != tt.want {
+				t.Errorf("want %s, got %s, stored %s", tt.want, w.Body.String(), s)
+			}
+			if got := w.Header().Get("ETag"); got != mustETag(t, tt.want) {
+				t.Errorf("want etag %s, got %s", mustETag(t, tt.want), got)
+			}
+		})
+	}
+}
+
+func TestHandler_acceptPatch(t *testing.T) {
+	s := newMemStore(t, `{}`)
+	r := httptest.NewRequest(http.MethodPatch, "/resource", strings.NewReader(`[]`))
+	w := httptest.NewRecorder()
+	NewHandler(s.load, s.store).ServeHTTP(w, r)
+	want := MediaTypeJSONPatch + ", " + MediaTypeMergePatch
+	if w.Code != http.StatusUnsupportedMediaType || w.Header().Get("Accept-Patch") != want {
+		t.Errorf("want 415 with Accept-Patch %s, got %d %q", want, w.Code, w.Header().Get("Accept-Patch"))
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+// Package httppatch 提供处理和发送 HTTP PATCH 请求的工具，
+// 支持 JSON Patch (RFC 6902) 和 JSON Merge Patch (RFC 7396)，并使用 ETag 和 If-Match 实现乐观并发控制。
+package httppatch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	// MediaTypeJSONPatch 是 JSON Patch (RFC 6902) 的媒体类型
+	MediaTypeJSONPatch = "application/json-patch+json"
+	// MediaTypeMergePatch 是 JSON Merge Patch (RFC 7396) 的媒体类型
+	MediaTypeMergePatch = "application/merge-patch+json"
+	// MediaTypeProblem 是错误响应 (RFC 7807) 的媒体类型
+	MediaTypeProblem = "application/problem+json"
+)
+
+var (
+	// ErrNotFound 由 LoadFunc 返回时，Handler 响应 404
+	ErrNotFound = errors.New("resource not found")
+	// ErrPreconditionFailed 由 StoreFunc 返回时（如资源在读取后已被修改），Handler 响应 412；
+	// Client 在重试次数用完后也会返回由它装饰的 error
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// ETag 返回 node 的强 ETag：node 的规范形式（见 decode.MarshalCanonical）的 SHA-256 的十六进制形式，带双引号。
+// key 的顺序和格式不同但内容相同的文档 ETag 相同
+func ETag(node *decode.JsonNode) (string, error) {
+	data, err := decode.MarshalCanonical(node)
+	if err != nil {
+		return "", errors.Wrap(err, "fail to marshal the document")
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`, nil
+}
+
+// matchETag 按照 RFC 7232 的强比较判断 If-Match 的值 header 是否匹配 etag
+func matchETag(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag && !strings.HasPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Problem 是 RFC 7807 定义的错误响应，Handler 以 application/problem+json 格式返回。
+// 应用 JSON Patch 失败时，Index、Op、Path 和 From 描述失败的操作，与 jsondiff.PatchError 相同
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Index  *int   `json:"index,omitempty"`
+	Op     string `json:"op,omitempty"`
+	Path   string `json:"path,omitempty"`
+	From   string `json:"from,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+// Is 使状态码为 404 和 412 的 Problem 分别满足 errors.Is(err, ErrNotFound) 和 errors.Is(err, ErrPreconditionFailed)
+func (p *Problem) Is(target error) bool {
+	return target == ErrNotFound && p.Status == http.StatusNotFound ||
+		target == ErrPreconditionFailed && p.Status == http.StatusPreconditionFailed
+}
+
+func newProblem(status int, err error) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: err.Error(),
+	}
+}
+
+func writeProblem(w http.ResponseWriter, p *Problem) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, p.Title, p.Status)
+		return
+	}
+	w.Header().Set("Content-Type", MediaTypeProblem)
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(data)
+}
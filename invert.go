@@ -0,0 +1,254 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"strconv"
+)
+
+// InvertPatch 返回差异 patch 的逆差异：把 patch 应用于 source 后得到的文档，再应用逆差异会还原为与 source 相等的文档。
+// 还原的对象中 key 的顺序可能不同：撤销对象成员的 remove 或 move 时使用 add，被还原的 key 会追加在对象末尾，
+// 因此结果与 source 在 Equal 意义下相等，但序列化后不一定与 source 逐字节相同。
+// 使用 UseTestGuardOption 可以在每一步撤销前添加 test 操作，使用 UseFullRemoveOption 时 remove 会带上被删除的值
+func InvertPatch(source, patch []byte, options ...DiffOption) ([]byte, error) {
+	patchNode, err := decode.Unmarshal(patch)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to unmarshal patch data")
+	}
+	srcNode, err := decode.Unmarshal(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to unmarshal source data")
+	}
+	res, err := InvertPatchNode(srcNode, patchNode, options...)
+	if err != nil {
+		return nil, err
+	}
+	return decode.Marshal(res)
+}
+
+// InvertPatchNode 在 source 的拷贝上依次应用 patch 中的每一个操作，并根据应用前后的文档生成对应的撤销操作，
+// 撤销操作中的数组下标都是确定的（不会出现 "-"），move 会被反向，copy 会被撤销为 remove 或 replace。
+// 与 InvertPatch 相同，还原的对象中 key 的顺序可能与 source 不同。
+// patch 总是按照 RFC 6902 严格模式应用，某个操作应用失败时返回的错误可以使用 errors.As 获取 *PatchError
+func InvertPatchNode(source, patch *decode.JsonNode, options ...DiffOption) (*decode.JsonNode, error) {
+	if source == nil {
+		return nil, errors.New("source is nil")
+	}
+	res := newDiffs()
+	if patch == nil {
+		return res.d, nil
+	}
+	if patch.Type != decode.JsonNodeTypeSlice {
+		return nil, errors.Wrap(decode.BadDiffsError, "diffs must be an array")
+	}
+	cfg := newDiffConfig(options)
+	cfg.flags &^= UseLenientMergeOption
+	work, err := DeepCopy(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to deep copy source")
+	}
+	steps := make([][]*decode.JsonNode, len(patch.Children))
+	for i, op := range patch.Children {
+		inverse, guard := invertOne(work, op, cfg.flags)
+		if err := mergeOne(work, op, cfg); err != nil {
+			return nil, errors.Wrap(newPatchError(i, op, err), "fail to invert")
+		}
+		if guard != nil && cfg.flags&UseTestGuardOption == UseTestGuardOption {
+			if value, ok := work.FindPointer(*guard); ok {
+				test := newDiffNode(DiffTypeTest, guard.String(), cloneNode(value), "", cfg.flags)
+				inverse = append([]*decode.JsonNode{test}, inverse...)
+			}
+		}
+		steps[i] = inverse
+	}
+	for i := len(steps) - 1; i >= 0; i-- {
+		for _, op := range steps[i] {
+			res.add(op)
+		}
+	}
+	return res.d, nil
+}
+
+// invertOne 根据应用 op 之前的文档 work 返回撤销 op 的操作，以及应用 op 之后需要 test 的路径，
+// op 不合法时返回 nil，由 mergeOne 报告错误
+func invertOne(work, op *decode.JsonNode, flags JsonDiffOption) ([]*decode.JsonNode, *decode.Pointer) {
+	if op == nil || op.Type != decode.JsonNodeTypeObject {
+		return nil, nil
+	}
+	name, err := diffString(op, "op")
+	if err != nil {
+		return nil, nil
+	}
+	path, err := diffString(op, "path")
+	if err != nil {
+		return nil, nil
+	}
+	p, err := decode.ParsePointer(path)
+	if err != nil {
+		return nil, nil
+	}
+	switch name {
+	case "add", "copy":
+		value := op.ChildrenMap["value"]
+		if name == "copy" {
+			value = findFrom(work, op)
+		}
+		return invertAdd(work, p, value, flags)
+	case "remove":
+		old, ok := work.FindPointer(p)
+		if !ok || p.IsRoot() {
+			return nil, nil
+		}
+		return []*decode.JsonNode{newDiffNode(DiffTypeAdd, p.String(), cloneNode(old), "", flags)}, nil
+	case "replace":
+		old, ok := work.FindPointer(p)
+		if !ok {
+			return nil, nil
+		}
+		return []*decode.JsonNode{newDiffNode(DiffTypeReplace, p.String(), cloneNode(old), "", flags)}, &p
+	case "move":
+		return invertMove(work, op, p, flags)
+	case "test":
+		return []*decode.JsonNode{cloneNode(op)}, nil
+	}
+	return nil, nil
+}
+
+// invertAdd 撤销在 p 处添加 value 的操作：数组中新增的元素和对象中新增的 key 会被删除，
+// 被覆盖的 key 会被替换回原来的值
+func invertAdd(work *decode.JsonNode, p decode.Pointer, value *decode.JsonNode, flags JsonDiffOption) ([]*decode.JsonNode, *decode.Pointer) {
+	if p.IsRoot() {
+		return []*decode.JsonNode{newDiffNode(DiffTypeReplace, "", cloneNode(work), "", flags)}, &p
+	}
+	parent, ok := work.FindPointer(p.Parent())
+	if !ok {
+		return nil, nil
+	}
+	switch parent.Type {
+	case decode.JsonNodeTypeSlice:
+		idx := len(parent.Children)
+		if p.Last() != "-" {
+			i, err := strconv.Atoi(p.Last())
+			if err != nil {
+				return nil, nil
+			}
+			idx = i
+		}
+		target := p.Parent().Append(strconv.Itoa(idx))
+		return []*decode.JsonNode{newDiffNode(DiffTypeRemove, target.String(), cloneNode(value), "", flags)}, &target
+	case decode.JsonNodeTypeObject:
+		if old, ok := parent.ChildrenMap[p.Last()]; ok {
+			return []*decode.JsonNode{newDiffNode(DiffTypeReplace, p.String(), cloneNode(old), "", flags)}, &p
+		}
+		return []*decode.JsonNode{newDiffNode(DiffTypeRemove, p.String(), cloneNode(value), "", flags)}, &p
+	}
+	return nil, nil
+}
+
+// invertMove 撤销从 from 移动到 p 的操作，通常只需要从移动后的位置移动回 from，
+// 如果移动覆盖了对象中已有的 key，还需要把原来的值添加回去
+func invertMove(work, op *decode.JsonNode, p decode.Pointer, flags JsonDiffOption) ([]*decode.JsonNode, *decode.Pointer) {
+	from, err := diffString(op, "from")
+	if err != nil {
+		return nil, nil
+	}
+	f, err := decode.ParsePointer(from)
+	if err != nil || f.String() == p.String() {
+		return nil, nil
+	}
+	// path 是 from 的祖先时，from 所在的子树会被覆盖，直接替换回原来的值
+	if p.IsPrefixOf(f) {
+		old, ok := work.FindPointer(p)
+		if !ok {
+			return nil, nil
+		}
+		return []*decode.JsonNode{newDiffNode(DiffTypeReplace, p.String(), cloneNode(old), "", flags)}, &p
+	}
+	// 不能移动到自己的子节点中
+	if f.IsPrefixOf(p) {
+		return nil, nil
+	}
+	if _, ok := work.FindPointer(f); !ok {
+		return nil, nil
+	}
+	// path 是删除 from 之后的文档中的位置，直接在 work 中查找它的父节点，不必复制整个文档：
+	// 如果 path 经过 from 所在的数组，下标不小于 from 的元素在 work 中的下标要加一
+	fromParent, _ := work.FindPointer(f.Parent())
+	tokens := p.Parent().Tokens()
+	depth := len(f.Tokens()) - 1
+	if fromParent.Type == decode.JsonNodeTypeSlice && len(tokens) > depth && f.Parent().IsPrefixOf(p) {
+		fi, _ := strconv.Atoi(f.Last())
+		if i, err := strconv.Atoi(tokens[depth]); err == nil && i >= fi {
+			tokens[depth] = strconv.Itoa(i + 1)
+		}
+	}
+	parentPath := decode.Pointer{}
+	for _, token := range tokens {
+		parentPath = parentPath.Append(token)
+	}
+	parent, ok := work.FindPointer(parentPath)
+	if !ok {
+		return nil, nil
+	}
+	target := p
+	switch parent.Type {
+	case decode.JsonNodeTypeSlice:
+		if p.Last() == "-" {
+			n := len(parent.Children)
+			if parent == fromParent {
+				n--
+			}
+			target = p.Parent().Append(strconv.Itoa(n))
+		}
+	case decode.JsonNodeTypeObject:
+		if old, ok := parent.ChildrenMap[p.Last()]; ok {
+			return []*decode.JsonNode{
+				newDiffNode(DiffTypeMove, f.String(), nil, p.String(), flags),
+				newDiffNode(DiffTypeAdd, p.String(), cloneNode(old), "", flags),
+			}, &p
+		}
+	}
+	return []*decode.JsonNode{newDiffNode(DiffTypeMove, f.String(), nil, target.String(), flags)}, &target
+}
+
+// findFrom 返回 copy 操作中 from 处的节点
+func findFrom(work, op *decode.JsonNode) *decode.JsonNode {
+	from, err := diffString(op, "from")
+	if err != nil {
+		return nil
+	}
+	f, err := decode.ParsePointer(from)
+	if err != nil {
+		return nil
+	}
+	node, _ := work.FindPointer(f)
+	return node
+}
+
+func cloneNode(node *decode.JsonNode) *decode.JsonNode {
+	if node == nil {
+		return nil
+	}
+	res, err := DeepCopy(node)
+	if err != nil {
+		return node
+	}
+	return res
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"fmt"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"math/rand"
+	"testing"
+)
+
+func TestInvertPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		patch   string
+		options []DiffOption
+		want    string
+	}{
+		{
+			"add and remove",
+			`{"a": [1, 2], "b": {"c": 1}}`,
+			`[{"op": "add", "path": "/a/-", "value": 3}, {"op": "remove", "path": "/b/c"}, {"op": "add", "path": "/d", "value": 4}]`,
+			nil,
+			`[{"op":"remove","path":"/d"},{"op":"add","path":"/b/c","value":1},{"op":"remove","path":"/a/2"}]`,
+		},
+		{
+			"replace keeps the old value",
+			`{"a": 1, "b": [1, 2, 3]}`,
+			`[{"op": "replace", "path": "/a", "value": 2}, {"op": "add", "path": "/a", "value": 3}, {"op": "replace", "path": "/b/1", "value": 9}]`,
+			nil,
+			`[{"op":"replace","path":"/b/1","value":2},{"op":"replace","path":"/a","value":2},{"op":"replace","path":"/a","value":1}]`,
+		},
+		{
+			"move and copy",
+			`{"a": [1, 2, 3], "b": {"c": 1}, "d": 0}`,
+			`[{"op": "move", "path": "/a/-", "from": "/a/0"}, {"op": "copy", "path": "/e", "from": "/b"}, {"op": "move", "path": "/d", "from": "/b/c"}]`,
+			nil,
+			`[{"op":"move","path":"/b/c","from":"/d"},{"op":"add","path":"/d","value":0},{"op":"remove","path":"/e"},{"op":"move","path":"/a/0","from":"/a/2"}]`,
+		},
+		{
+			// 被还原的 key 追加在对象末尾，结果与 src 只在 key 的顺序上不同
+			"remove an object member",
+			`{"a": 1, "b": 2}`,
+			`[{"op": "remove", "path": "/a"}]`,
+			nil,
+			`[{"op":"add","path":"/a","value":1}]`,
+		},
+		{
+			"move to an ancestor",
+			`{"a": {"b": {"c": 1}}}`,
+			`[{"op": "move", "path": "/a", "from": "/a/b"}]`,
+			nil,
+			`[{"op":"replace","path":"/a","value":{"b":{"c":1}}}]`,
+		},
+		{
+			"move into a later sibling",
+			`{"a": [{"x": 1}, {"y": 2}, {"z": 3}], "b": {"c": 1}}`,
+			`[{"op": "move", "path": "/a/1/k", "from": "/a/0"}, {"op": "move", "path": "/b/c", "from": "/a/0/y"}]`,
+			nil,
+			`[{"op":"move","path":"/a/0/y","from":"/b/c"},{"op":"add","path":"/b/c","value":1},{"op":"move","path":"/a/0","from":"/a/1/k"}]`,
+		},
+		{
+			"root",
+			`{"a": 1}`,
+			`[{"op": "replace", "path": "", "value": [1]}, {"op": "test", "path": "/0", "value": 1}]`,
+			nil,
+			`[{"op":"test","path":"/0","value":1},{"op":"replace","path":"","value":{"a":1}}]`,
+		},
+		{
+			"test guard",
+			`{"a": [1, 2], "b": 1}`,
+			`[{"op": "add", "path": "/a/0", "value": 0}, {"op": "replace", "path": "/b", "value": 2}, {"op": "remove", "path": "/a/1"}]`,
+			[]DiffOption{UseTestGuardOption, UseFullRemoveOption},
+			`[{"op":"add","path":"/a/1","value":1},{"op":"test","path":"/b","value":2},{"op":"replace","path":"/b","value":1},` +
+				`{"op":"test","path":"/a/0","value":0},{"op":"remove","path":"/a/0","value":0}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InvertPatch([]byte(tt.src), []byte(tt.patch), tt.options...)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+			dst, err := MergeDiff([]byte(tt.src), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			res, err := MergeDiff(dst, got)
+			if err != nil {
+				t.Fatalf("fail to apply the inverse patch: %v", err)
+			}
+			// 撤销对象成员的 remove 时 key 会追加在末尾，只比较内容，不比较 key 的顺序
+			a, _ := decode.Unmarshal(res)
+			b, _ := decode.Unmarshal([]byte(tt.src))
+			if !a.Equal(b) {
+				t.Errorf("want %s, got %s", tt.src, res)
+			}
+		})
+	}
+}
+
+func TestInvertPatch_error(t *testing.T) {
+	_, err := InvertPatch([]byte(`{"a": 1}`), []byte(`[{"op": "test", "path": "/a", "value": 1}, {"op": "remove", "path": "/b"}]`))
+	var pe *PatchError
+	if !errors.As(err, &pe) || pe.Index != 1 || !errors.Is(err, ErrPathNotFound) {
+		t.Errorf("want a PatchError at 1, got %v", err)
+	}
+	_, err = InvertPatch([]byte(`{"a": 1}`), []byte(`{"op": "remove", "path": "/a"}`))
+	if !errors.Is(err, decode.BadDiffsError) {
+		t.Errorf("want BadDiffsError, got %v", err)
+	}
+}
+
+func TestInvertPatch_roundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	doc := func() string {
+		s := "{"
+		for i, k := range r.Perm(5)[:r.Intn(5)] {
+			if i > 0 {
+				s += ","
+			}
+			s += fmt.Sprintf(`"%d": [`, k)
+			for j := 0; j < r.Intn(5); j++ {
+				if j > 0 {
+					s += ","
+				}
+				s += fmt.Sprintf(`{"v": %d}`, r.Intn(3))
+			}
+			s += "]"
+		}
+		return s + "}"
+	}
+	options := []DiffOption{UseMoveOption, UseCopyOption}
+	for i := 0; i < 300; i++ {
+		src, tar := doc(), doc()
+		patch, err := AsDiffs([]byte(src), []byte(tar), options[r.Intn(len(options))])
+		if err != nil {
+			t.Fatalf("got an error: %v", err)
+		}
+		dst, err := MergeDiff([]byte(src), patch)
+		if err != nil {
+			// UseMoveOption 在下标变化时可能生成无法应用的差异，与 InvertPatch 无关
+			continue
+		}
+		inverse, err := InvertPatch([]byte(src), patch, UseTestGuardOption)
+		if err != nil {
+			t.Fatalf("%s -> %s: %s: %v", src, tar, patch, err)
+		}
+		res, err := MergeDiff(dst, inverse)
+		if err != nil {
+			t.Fatalf("%s -> %s: %s: fail to apply %s: %v", src, tar, patch, inverse, err)
+		}
+		// Equal 不比较 key 的顺序，被还原的 key 可能在对象末尾
+		a, _ := decode.Unmarshal(res)
+		b, _ := decode.Unmarshal([]byte(src))
+		if !a.Equal(b) {
+			t.Fatalf("%s -> %s: %s: %s got %s", src, tar, patch, inverse, res)
+		}
+	}
+}
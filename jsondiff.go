@@ -1,46 +1,43 @@
 package json_diff
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
 	"github.com/520MianXiangDuiXiang520/json-diff/decode"
 	"github.com/pkg/errors"
+	"sort"
 	"strconv"
 )
 
-func diffSlice(diffs *diffs, path string, source, patch *decode.JsonNode, option JsonDiffOption) {
-	lcsList := longestCommonSubsequence(source.Children, patch.Children)
+func diffSlice(diffs *diffs, path decode.Pointer, source, patch *decode.JsonNode, cfg *diffConfig) {
+	lcsList := longestCommonSubsequence(source.Children, patch.Children, func(i, j int) bool {
+		return cfg.equal(path.Append(strconv.Itoa(i)), source.Children[i], patch.Children[j])
+	})
 	lcsIdx := 0
 	srcIdx := 0
 	tarIdx := 0
 	pos := 0
 	for lcsIdx < len(lcsList) {
-		pathBuffer := bytes.NewBufferString(path)
 		srcNode := source.Children[srcIdx]
 		lcsNode := lcsList[lcsIdx]
 		tarNode := patch.Children[tarIdx]
-		if lcsNode.Equal(srcNode) && lcsNode.Equal(tarNode) {
+		currPath := path.Append(strconv.Itoa(pos))
+		elemPath := path.Append(strconv.Itoa(srcIdx))
+		srcMatch := cfg.equal(elemPath, lcsNode, srcNode)
+		tarMatch := cfg.equal(elemPath, lcsNode, tarNode)
+		if srcMatch && tarMatch {
 			lcsIdx++
 			srcIdx++
 			tarIdx++
 			pos++
 		} else {
-			if lcsNode.Equal(srcNode) {
-				pathBuffer.WriteString("/")
-				pathBuffer.WriteString(strconv.Itoa(pos))
-				diffs.add(newDiffNode(DiffTypeAdd, pathBuffer.String(), tarNode, "", option))
+			if srcMatch {
+				diffs.add(newDiffNode(DiffTypeAdd, currPath.String(), tarNode, "", cfg.flags))
 				tarIdx++
 				pos++
-			} else if lcsNode.Equal(tarNode) {
-				pathBuffer.WriteString("/")
-				pathBuffer.WriteString(strconv.Itoa(pos))
-				diffs.add(newDiffNode(DiffTypeRemove, pathBuffer.String(), srcNode, "", option))
+			} else if tarMatch {
+				diffs.add(newDiffNode(DiffTypeRemove, currPath.String(), srcNode, "", cfg.flags))
 				srcIdx++
 			} else {
-				pathBuffer.WriteString("/")
-				pathBuffer.WriteString(strconv.Itoa(pos))
-				diff(diffs, pathBuffer.String(), srcNode, tarNode, option)
+				diff(diffs, currPath, srcNode, tarNode, cfg)
 				srcIdx++
 				tarIdx++
 				pos++
@@ -49,12 +46,9 @@ func diffSlice(diffs *diffs, path string, source, patch *decode.JsonNode, option
 	}
 
 	for srcIdx < len(source.Children) && tarIdx < len(patch.Children) {
-		pathBuffer := bytes.NewBufferString(path)
 		srcNode := source.Children[srcIdx]
 		tarNode := patch.Children[tarIdx]
-		pathBuffer.WriteString("/")
-		pathBuffer.WriteString(strconv.Itoa(pos))
-		diff(diffs, pathBuffer.String(), srcNode, tarNode, option)
+		diff(diffs, path.Append(strconv.Itoa(pos)), srcNode, tarNode, cfg)
 		srcIdx++
 		tarIdx++
 		pos++
@@ -62,152 +56,277 @@ func diffSlice(diffs *diffs, path string, source, patch *decode.JsonNode, option
 
 	// 如果 source 或 patch 后面还有，属于 add 或 remove
 	for ; srcIdx < len(source.Children); srcIdx++ {
-		pathBuffer := bytes.NewBufferString(path)
-		pathBuffer.WriteString("/")
-		pathBuffer.WriteString(strconv.Itoa(pos))
-		diffs.add(newDiffNode(DiffTypeRemove, pathBuffer.String(), source.Children[srcIdx], "", option))
-		pos++
+		currPath := path.Append(strconv.Itoa(pos))
+		diffs.add(newDiffNode(DiffTypeRemove, currPath.String(), source.Children[srcIdx], "", cfg.flags))
 	}
 
 	for ; tarIdx < len(patch.Children); tarIdx++ {
-		pathBuffer := bytes.NewBufferString(path)
-		pathBuffer.WriteString("/")
-		pathBuffer.WriteString(strconv.Itoa(pos))
-		diffs.add(newDiffNode(DiffTypeAdd, pathBuffer.String(), patch.Children[tarIdx], "", option))
+		currPath := path.Append(strconv.Itoa(pos))
+		diffs.add(newDiffNode(DiffTypeAdd, currPath.String(), patch.Children[tarIdx], "", cfg.flags))
 		pos++
 	}
 }
 
-func diffObject(diffs *diffs, path string, source, patch *decode.JsonNode, option JsonDiffOption) {
-	for srcKey, srcValue := range source.ChildrenMap {
-		tarVal, tarOk := patch.ChildrenMap[srcKey]
-		currPath := fmt.Sprintf("%s/%s", path, srcKey)
-		if !tarOk {
-			diffs.add(newDiffNode(DiffTypeRemove, currPath, srcValue, "", option))
-			continue
+// diffObject 比较两个对象，默认先按 source 中 key 的顺序输出删除和修改，再按 patch 中 key 的顺序输出新增；
+// 开启 UseSortedPathOption 时按 key 的字典序输出
+func diffObject(diffs *diffs, path decode.Pointer, source, patch *decode.JsonNode, cfg *diffConfig) {
+	keys := source.ObjectKeys()
+	for _, tarKey := range patch.ObjectKeys() {
+		if _, srcOk := source.ChildrenMap[tarKey]; !srcOk {
+			keys = append(keys, tarKey)
 		}
-		diff(diffs, currPath, srcValue, tarVal, option)
 	}
-
-	for tarKey, tarVal := range patch.ChildrenMap {
-		_, srcOk := source.ChildrenMap[tarKey]
-		if !srcOk {
-			currPath := fmt.Sprintf("%s/%s", path, tarKey)
-			diffs.add(newDiffNode(DiffTypeAdd, currPath, tarVal, "", option))
+	if cfg.flags&UseSortedPathOption == UseSortedPathOption {
+		sort.Strings(keys)
+	}
+	for _, key := range keys {
+		srcValue, srcOk := source.ChildrenMap[key]
+		tarVal, tarOk := patch.ChildrenMap[key]
+		currPath := path.Append(key)
+		switch {
+		case (!tarOk || !srcOk) && cfg.filter(currPath) != filterKeep:
+			continue
+		case !tarOk:
+			diffs.add(newDiffNode(DiffTypeRemove, currPath.String(), srcValue, "", cfg.flags))
+		case !srcOk:
+			diffs.add(newDiffNode(DiffTypeAdd, currPath.String(), tarVal, "", cfg.flags))
+		default:
+			diff(diffs, currPath, srcValue, tarVal, cfg)
 		}
 	}
 }
 
-func diff(diffs *diffs, path string, source, patch *decode.JsonNode, option JsonDiffOption) {
+func diff(diffs *diffs, path decode.Pointer, source, patch *decode.JsonNode, cfg *diffConfig) {
+	switch cfg.filter(path) {
+	case filterSkip:
+		return
+	case filterDescend:
+		diffAncestor(diffs, path, source, patch, cfg)
+		return
+	}
+	if c, ok := cfg.comparator(path, source, patch); ok {
+		diffWithComparator(diffs, path, source, patch, c, cfg)
+		return
+	}
 	if source == nil && patch != nil {
-		diffs.add(newDiffNode(DiffTypeAdd, path, patch, "", option))
+		diffs.add(newDiffNode(DiffTypeAdd, path.String(), patch, "", cfg.flags))
 	}
 	if source != nil && patch == nil {
-		diffs.add(newDiffNode(DiffTypeRemove, path, nil, "", option))
+		diffs.add(newDiffNode(DiffTypeRemove, path.String(), nil, "", cfg.flags))
 	}
 	if source != nil && patch != nil {
 		if source.Type == decode.JsonNodeTypeObject && patch.Type == decode.JsonNodeTypeObject {
-			diffObject(diffs, path, source, patch, option)
+			diffObject(diffs, path, source, patch, cfg)
 		} else if source.Type == decode.JsonNodeTypeSlice && patch.Type == decode.JsonNodeTypeSlice {
-			diffSlice(diffs, path, source, patch, option)
+			if cfg.elementsExcluded(path) {
+				return
+			} else if cfg.unordered(path) {
+				diffUnorderedSlice(diffs, path, source, patch, cfg)
+			} else if key, ok := cfg.arrayKey(path); !ok || !diffKeyedSlice(diffs, path, source, patch, key, cfg) {
+				diffSlice(diffs, path, source, patch, cfg)
+			}
 		} else {
 			// 两个都是 JsonNodeTypeValue
-			if !source.Equal(patch) {
-				diffs.add(newDiffNode(DiffTypeReplace, path, patch, "", option))
+			if !cfg.equal(path, source, patch) {
+				diffs.add(newDiffNode(DiffTypeReplace, path.String(), patch, "", cfg.flags))
 			}
 		}
 	}
 }
 
 // GetDiffNode 比较两个 JsonNode 之间的差异，并返回 JsonNode 格式的差异结果
-func GetDiffNode(sourceJsonNode, patchJsonNode *decode.JsonNode, options ...JsonDiffOption) *decode.JsonNode {
-	option := JsonDiffOption(0)
-	for _, o := range options {
-		option |= o
-	}
+func GetDiffNode(sourceJsonNode, patchJsonNode *decode.JsonNode, options ...DiffOption) *decode.JsonNode {
+	cfg := newDiffConfig(options)
 	diffs := newDiffs()
-	diff(diffs, "", sourceJsonNode, patchJsonNode, option)
-	doOption(diffs, option, sourceJsonNode, patchJsonNode)
+	diff(diffs, decode.Pointer{}, sourceJsonNode, patchJsonNode, cfg)
+	doOption(diffs, cfg.flags, sourceJsonNode, patchJsonNode)
 	return diffs.d
 }
 
 // AsDiffs 比较 patch 相比于 source 的差别，返回 json 格式的差异文档。
-func AsDiffs(source, patch []byte, options ...JsonDiffOption) ([]byte, error) {
-	// sourceJsonNode, err := decode.Unmarshal(source)
-    sourceJsonNode, err := Unmarshal(source)
+func AsDiffs(source, patch []byte, options ...DiffOption) ([]byte, error) {
+	sourceJsonNode, err := decode.Unmarshal(source)
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to unmarshal src")
 	}
-	// patchJsonNode, err := decode.Unmarshal(patch)
-    patchJsonNode, err := Unmarshal(patch)
+	patchJsonNode, err := decode.Unmarshal(patch)
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to unmarshal tar")
 	}
-	dict := marshalSlice(GetDiffNode(sourceJsonNode, patchJsonNode, options...))
-	return json.Marshal(dict)
+	return decode.Marshal(GetDiffNode(sourceJsonNode, patchJsonNode, options...))
 }
 
-func merge(srcNode, diffNode *decode.JsonNode) error {
-	for _, diff := range diffNode.Children {
-		if diff.Type != decode.JsonNodeTypeObject {
-			return errors.WithStack(decode.BadDiffsError)
-		}
-		op := diff.ChildrenMap["op"].Value
-		path := diff.ChildrenMap["path"].Value.(string)
-		switch op {
-		case "add":
-			err := decode.AddPath(srcNode, path, diff.ChildrenMap["value"])
-			if err != nil {
-				return err
-			}
-		case "remove":
-			_, err := decode.RemovePath(srcNode, path)
-			if err != nil {
-				return err
-			}
-		case "replace":
-			val := diff.ChildrenMap["value"]
-			_, err := decode.ReplacePath(srcNode, path, val)
-			if err != nil {
-				return err
-			}
-		case "move":
-			from := diff.ChildrenMap["from"].Value.(string)
-			_, err := decode.MovePath(srcNode, from, path)
-			if err != nil {
-				return err
-			}
-		case "copy":
-			from := diff.ChildrenMap["from"].Value.(string)
-			err := decode.CopyPath(srcNode, from, path)
-			if err != nil {
-				return err
-			}
-		case "test":
-			err := decode.ATestPath(srcNode, path, diff.ChildrenMap["value"])
-			if err != nil {
-				return err
+func merge(srcNode, diffNode *decode.JsonNode, cfg *diffConfig) error {
+	for i, diff := range diffNode.Children {
+		if err := mergeOne(srcNode, diff, cfg); err != nil {
+			return newPatchError(i, diff, err)
+		}
+	}
+	return nil
+}
+
+// mergeOne 将一个差异 diff 应用于 srcNode 上
+func mergeOne(srcNode, diff *decode.JsonNode, cfg *diffConfig) error {
+	lenient := cfg.flags&UseLenientMergeOption == UseLenientMergeOption
+	if diff == nil || diff.Type != decode.JsonNodeTypeObject {
+		return errors.WithStack(decode.BadDiffsError)
+	}
+	op, err := diffString(diff, "op")
+	if err != nil {
+		return err
+	}
+	path, err := diffString(diff, "path")
+	if err != nil {
+		return err
+	}
+	switch op {
+	case "add":
+		val, err := diffValue(diff)
+		if err != nil {
+			return err
+		}
+		if lenient {
+			err = lenientAdd(srcNode, path, val)
+		} else {
+			err = decode.AddPath(srcNode, path, val)
+		}
+		if err != nil {
+			return err
+		}
+	case "remove":
+		_, err := decode.RemovePath(srcNode, path)
+		if err != nil {
+			return err
+		}
+	case "replace":
+		val, err := diffValue(diff)
+		if err != nil {
+			return err
+		}
+		if lenient {
+			err = lenientReplace(srcNode, path, val)
+		} else {
+			_, err = decode.ReplacePath(srcNode, path, val)
+		}
+		if err != nil {
+			return err
+		}
+	case "move":
+		from, err := diffString(diff, "from")
+		if err != nil {
+			return err
+		}
+		if lenient {
+			err = lenientMove(srcNode, from, path)
+		} else {
+			_, err = decode.MovePath(srcNode, from, path)
+		}
+		if err != nil {
+			return err
+		}
+	case "copy":
+		from, err := diffString(diff, "from")
+		if err != nil {
+			return err
+		}
+		err = decode.CopyPath(srcNode, from, path)
+		if err != nil {
+			return err
+		}
+	case "test":
+		val, err := diffValue(diff)
+		if err != nil {
+			return err
+		}
+		if cfg.customEqual() {
+			err = decode.ATestPathWith(srcNode, path, val, cfg.equal)
+		} else {
+			err = decode.ATestPath(srcNode, path, val)
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.Wrapf(decode.ErrInvalidOperation, "unknown op %s", op)
+	}
+	return nil
+}
+
+// diffString 返回差异 diff 中字符串类型的字段 key
+func diffString(diff *decode.JsonNode, key string) (string, error) {
+	v, ok := diff.ChildrenMap[key]
+	if !ok || v == nil {
+		return "", errors.Wrapf(decode.BadDiffsError, "missing %s", key)
+	}
+	s, ok := v.Value.(string)
+	if v.Type != decode.JsonNodeTypeValue || !ok {
+		return "", errors.Wrapf(decode.BadDiffsError, "%s must be a string", key)
+	}
+	return s, nil
+}
+
+// diffValue 返回差异 diff 中的 value 字段
+func diffValue(diff *decode.JsonNode) (*decode.JsonNode, error) {
+	v, ok := diff.ChildrenMap["value"]
+	if !ok || v == nil {
+		return nil, errors.Wrap(decode.BadDiffsError, "missing value")
+	}
+	return v, nil
+}
+
+// lenientAdd 与 decode.AddPath 相同，但数组下标越界时会将 value 追加到数组末尾
+func lenientAdd(srcNode *decode.JsonNode, path string, value *decode.JsonNode) error {
+	p, err := decode.ParsePointer(path)
+	if err == nil && !p.IsRoot() {
+		f, ok := srcNode.FindPointer(p.Parent())
+		if ok && f.Type == decode.JsonNodeTypeSlice {
+			if index, err := strconv.Atoi(p.Last()); err == nil {
+				if index < 0 || index > len(f.Children) {
+					return f.Append(value)
+				}
+				return f.ADD(index, value)
 			}
-		default:
-			return errors.New(fmt.Sprintf("bad diffs: %v", diff))
 		}
 	}
+	return decode.AddPath(srcNode, path, value)
+}
+
+// lenientReplace 与 decode.ReplacePath 相同，但对象中不存在 path 对应的 key 时会直接添加
+func lenientReplace(srcNode *decode.JsonNode, path string, value *decode.JsonNode) error {
+	if _, ok := srcNode.Find(path); !ok {
+		return lenientAdd(srcNode, path, value)
+	}
+	_, err := decode.ReplacePath(srcNode, path, value)
+	return err
+}
+
+// lenientMove 先在 path 处替换或添加 from 处的节点，再删除 from 处的节点
+func lenientMove(srcNode *decode.JsonNode, from, path string) error {
+	fromNode, ok := srcNode.Find(from)
+	if !ok {
+		return errors.Wrapf(decode.ErrPathNotFound, "fail to move: from path(%s) not find", from)
+	}
+	if err := lenientReplace(srcNode, path, fromNode); err != nil {
+		return decode.WrapJsonNodeError("move", err)
+	}
+	if _, err := decode.RemovePath(srcNode, from); err != nil {
+		return decode.WrapJsonNodeError("move", err)
+	}
 	return nil
 }
 
-// MergeDiff 根据差异文档 diff 还原 source 的差异
-func MergeDiff(source, diff []byte) ([]byte, error) {
-	// diffNode, err := decode.Unmarshal(diff)
-    diffNode, err := Unmarshal(diff)
-    if err != nil {
+// MergeDiff 根据差异文档 diff 还原 source 的差异，
+// 默认严格遵循 RFC 6902，使用 UseLenientMergeOption 可以切换为宽松模式
+func MergeDiff(source, diff []byte, options ...DiffOption) ([]byte, error) {
+	diffNode, err := decode.Unmarshal(diff)
+	if err != nil {
 		return nil, errors.Wrap(err, "fail to unmarshal diff data")
 	}
-	// srcNode, err := decode.Unmarshal(source)
-    srcNode, err := Unmarshal(source)
+	srcNode, err := decode.Unmarshal(source)
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to unmarshal source data")
 	}
-	result, err := MergeDiffNode(srcNode, diffNode)
+	result, err := MergeDiffNode(srcNode, diffNode, options...)
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to merge diff")
 	}
@@ -215,19 +334,23 @@ func MergeDiff(source, diff []byte) ([]byte, error) {
 }
 
 // MergeDiffNode 将 JsonNode 类型的 diffs 应用于源 source 上，并返回合并后的新 jsonNode 对象
-// 如果 diffs 不合法，第二个参数将会返回 BadDiffsError
-func MergeDiffNode(source, diffs *decode.JsonNode) (*decode.JsonNode, error) {
+// 如果 diffs 不是数组，第二个参数将会返回 BadDiffsError；
+// 如果某个差异应用失败，可以使用 errors.As 从返回的错误中获取 *PatchError
+func MergeDiffNode(source, diffs *decode.JsonNode, options ...DiffOption) (*decode.JsonNode, error) {
 	if diffs == nil {
 		return source, nil
 	}
+	if source == nil {
+		return nil, errors.New("source is nil")
+	}
 	if diffs.Type != decode.JsonNodeTypeSlice {
-		return nil, errors.New("bad diffs")
+		return nil, errors.Wrap(decode.BadDiffsError, "diffs must be an array")
 	}
 	copyNode, err := DeepCopy(source)
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to deep copy source")
 	}
-	err = merge(copyNode, diffs)
+	err = merge(copyNode, diffs, newDiffConfig(options))
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to merge")
 	}
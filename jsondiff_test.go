@@ -3,8 +3,10 @@ package json_diff
 import (
 	"fmt"
 	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
 	"io/ioutil"
 	"log"
+	"strconv"
 	"testing"
 )
 
@@ -106,10 +108,11 @@ func m(n *decode.JsonNode) []byte {
 	return r
 }
 
-func getOptions(n *decode.JsonNode) []JsonDiffOption {
-	res := make([]JsonDiffOption, len(n.Children))
+func getOptions(n *decode.JsonNode) []DiffOption {
+	res := make([]DiffOption, len(n.Children))
 	for i, v := range n.Children {
-		res[i] = JsonDiffOption(v.Value.(float64))
+		o, _ := v.Value.(decode.Number).Int64()
+		res[i] = JsonDiffOption(o)
 	}
 	return res
 }
@@ -118,7 +121,7 @@ func TestGetDiff(t *testing.T) {
 	type args struct {
 		source  []byte
 		patch   []byte
-		options []JsonDiffOption
+		options []DiffOption
 	}
 	fileName := "./test_data/getDiffTest.json"
 	input, err := ioutil.ReadFile(fileName)
@@ -192,3 +195,212 @@ func TestAsDiffsIssue5(t *testing.T) {
 	}
 	fmt.Println(string(diffs))
 }
+
+func TestMergeDiff_keepKeyOrder(t *testing.T) {
+	source := `{"name":"a","version":1,"deps":{"z":"1","b":"2"},"scripts":{}}`
+	diffs := `[
+        {"op": "replace", "path": "/version", "value": 2},
+        {"op": "add", "path": "/deps/a", "value": "3"},
+        {"op": "remove", "path": "/deps/z"},
+        {"op": "add", "path": "/scripts/test", "value": {"cmd": "go", "args": []}}
+      ]`
+	want := `{"name":"a","version":2,"deps":{"b":"2","a":"3"},"scripts":{"test":{"cmd":"go","args":[]}}}`
+	res, err := MergeDiff([]byte(source), []byte(diffs))
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	if string(res) != want {
+		t.Errorf("want %s, got %s", want, res)
+	}
+}
+
+func TestAsDiffs_stableOrder(t *testing.T) {
+	json1 := `{"z": 1, "b": {"y": [1, 2, 3], "x": "a"}, "a": [{"k": 1}], "m": true}`
+	json2 := `{"n": 2, "b": {"x": "b", "w": [1, 3]}, "a": [{"k": 1}, {"k": 1}], "z": 1, "c": 3}`
+	tests := []struct {
+		name    string
+		options []DiffOption
+		want    string
+	}{
+		{
+			"document order", []DiffOption{UseCopyOption, UseMoveOption},
+			`[{"op":"remove","path":"/b/y"},{"op":"replace","path":"/b/x","value":"b"},` +
+				`{"op":"add","path":"/b/w","value":[1,3]},{"op":"copy","path":"/a/1","from":"/a/0"},` +
+				`{"op":"remove","path":"/m"},{"op":"add","path":"/n","value":2},{"op":"add","path":"/c","value":3}]`,
+		},
+		{
+			"sorted path", []DiffOption{UseSortedPathOption, UseFullRemoveOption},
+			`[{"op":"add","path":"/a/1","value":{"k":1}},{"op":"add","path":"/b/w","value":[1,3]},` +
+				`{"op":"replace","path":"/b/x","value":"b"},{"op":"remove","path":"/b/y","value":[1,2,3]},` +
+				`{"op":"add","path":"/c","value":3},{"op":"remove","path":"/m","value":true},{"op":"add","path":"/n","value":2}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				got, err := AsDiffs([]byte(json1), []byte(json2), tt.options...)
+				if err != nil {
+					t.Fatalf("got an error: %v", err)
+				}
+				if string(got) != tt.want {
+					t.Fatalf("run %d: want %s, got %s", i, tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAsDiffs_escapedKeys(t *testing.T) {
+	json1 := `{"a/b": {"~x": 1}, "": [1], "m~1n": true}`
+	json2 := `{"a/b": {"~x": 2}, "": [1, 2], "c/~d": null}`
+	want := `[{"op":"replace","path":"/a~1b/~0x","value":2},{"op":"add","path":"//1","value":2},` +
+		`{"op":"remove","path":"/m~01n"},{"op":"add","path":"/c~1~0d","value":null}]`
+	diffs, err := AsDiffs([]byte(json1), []byte(json2))
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	if string(diffs) != want {
+		t.Errorf("want %s, got %s", want, diffs)
+	}
+	res, err := MergeDiff([]byte(json1), diffs)
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	got, _ := decode.Unmarshal(res)
+	target, _ := decode.Unmarshal([]byte(json2))
+	if !got.Equal(target) {
+		t.Errorf("want %s, got %s", json2, res)
+	}
+}
+
+func runJsonPatchTests(t *testing.T, fileName string) {
+	input, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("fail to open the %s", fileName)
+	}
+	cases, err := decode.Unmarshal(input)
+	if err != nil {
+		t.Fatalf("fail to unmarshal %s: %v", fileName, err)
+	}
+	for i, cs := range cases.Children {
+		name := strconv.Itoa(i)
+		if comment, ok := cs.ChildrenMap["comment"]; ok {
+			name = fmt.Sprintf("%d %s", i, comment.Value)
+		}
+		t.Run(name, func(t *testing.T) {
+			if disabled, ok := cs.ChildrenMap["disabled"]; ok && disabled.Value == true {
+				t.Skip("disabled")
+			}
+			if _, ok := cs.ChildrenMap["patch"]; !ok {
+				t.Skip("no patch")
+			}
+			res, err := MergeDiffNode(cs.ChildrenMap["doc"], cs.ChildrenMap["patch"])
+			if want, ok := cs.ChildrenMap["error"]; ok {
+				if err == nil {
+					t.Errorf("want error %q, got %s", want.Value, m(res))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if want, ok := cs.ChildrenMap["expected"]; ok && !res.Equal(want) {
+				t.Errorf("want %s, got %s", m(want), m(res))
+			}
+		})
+	}
+}
+
+func TestMergeDiff_jsonPatchTests(t *testing.T) {
+	if _, err := ioutil.ReadFile("./test_data/json-patch-tests/UPSTREAM"); err != nil {
+		t.Log("test_data/json-patch-tests is not synced with upstream, see its README.md")
+	}
+	runJsonPatchTests(t, "./test_data/json-patch-tests/tests.json")
+	runJsonPatchTests(t, "./test_data/json-patch-tests/spec_tests.json")
+}
+
+func TestMergeDiff_lenient(t *testing.T) {
+	source := `{"a": [1, 2], "b": {"c": 1}}`
+	tests := []struct {
+		diffs string
+		want  string
+	}{
+		{`[{"op": "add", "path": "/a/5", "value": 3}]`, `{"a":[1,2,3],"b":{"c":1}}`},
+		{`[{"op": "add", "path": "/a/-1", "value": 3}]`, `{"a":[1,2,3],"b":{"c":1}}`},
+		{`[{"op": "replace", "path": "/b/d", "value": 2}]`, `{"a":[1,2],"b":{"c":1,"d":2}}`},
+	}
+	for _, tt := range tests {
+		if _, err := MergeDiff([]byte(source), []byte(tt.diffs)); err == nil {
+			t.Errorf("%s: want an error in strict mode", tt.diffs)
+		}
+		res, err := MergeDiff([]byte(source), []byte(tt.diffs), UseLenientMergeOption)
+		if err != nil {
+			t.Fatalf("%s: got an error: %v", tt.diffs, err)
+		}
+		got, _ := decode.Unmarshal(res)
+		want, _ := decode.Unmarshal([]byte(tt.want))
+		if !got.Equal(want) {
+			t.Errorf("%s: want %s, got %s", tt.diffs, tt.want, res)
+		}
+	}
+}
+
+func TestAsDiffs_numbers(t *testing.T) {
+	json1 := `{"id": 9007199254740993, "price": 0.10, "qty": 1, "rate": 0.1}`
+	json2 := `{"id": 9007199254740992, "price": 0.1, "qty": 1.0, "rate": 0.1000000000000000055511151231257827}`
+	want := `[{"op":"replace","path":"/id","value":9007199254740992},` +
+		`{"op":"replace","path":"/rate","value":0.1000000000000000055511151231257827}]`
+	diffs, err := AsDiffs([]byte(json1), []byte(json2))
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	if string(diffs) != want {
+		t.Errorf("want %s, got %s", want, diffs)
+	}
+	res, err := MergeDiff([]byte(json1), diffs)
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	if w := `{"id":9007199254740992,"price":0.10,"qty":1,"rate":0.1000000000000000055511151231257827}`; string(res) != w {
+		t.Errorf("want %s, got %s", w, res)
+	}
+}
+
+func TestAsDiffs_testGuard(t *testing.T) {
+	json1 := `{"a": [1, 2, 3], "b": {"c": 1}, "d": "x"}`
+	json2 := `{"a": [1, 3], "e": {"c": 1}, "d": "y"}`
+	want := `[{"op":"test","path":"/a/1","value":2},{"op":"remove","path":"/a/1"},` +
+		`{"op":"test","path":"/b","value":{"c":1}},{"op":"move","path":"/e","from":"/b"},` +
+		`{"op":"test","path":"/d","value":"x"},{"op":"replace","path":"/d","value":"y"}]`
+	diffs, err := AsDiffs([]byte(json1), []byte(json2), UseMoveOption, UseTestGuardOption)
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	if string(diffs) != want {
+		t.Errorf("want %s, got %s", want, diffs)
+	}
+	if _, err := MergeDiff([]byte(json1), diffs); err != nil {
+		t.Errorf("got an error: %v", err)
+	}
+	_, err = MergeDiff([]byte(`{"a": [1, 2, 3], "b": {"c": 1}, "d": "z"}`), diffs)
+	if !errors.Is(err, ErrTestFailed) {
+		t.Errorf("want ErrTestFailed, got %v", err)
+	}
+}
+
+func TestAsDiffs_testGuardFallback(t *testing.T) {
+	src, _ := decode.Unmarshal([]byte(`{"a": 1, "b": 2}`))
+	diffs := newDiffs()
+	diffs.add(newDiffNode(DiffTypeReplace, "/a", decode.NewValueNode(3, 0), "", UseTestGuardOption))
+	diffs.add(newDiffNode(DiffTypeRemove, "/x", nil, "", UseTestGuardOption))
+	diffs.add(newDiffNode(DiffTypeReplace, "/b", decode.NewValueNode(4, 0), "", UseTestGuardOption))
+	diffs.add(newDiffNode(DiffTypeRemove, "/a", nil, "", UseTestGuardOption))
+	// 删除 /x 失败之后无法知道 /b 和 /a 当时的值，不再添加 test
+	doTestGuardOption(diffs, UseTestGuardOption, src)
+	want := `[{"op":"test","path":"/a","value":1},{"op":"replace","path":"/a","value":3},{"op":"remove","path":"/x"},` +
+		`{"op":"replace","path":"/b","value":4},{"op":"remove","path":"/a"}]`
+	got, _ := decode.Marshal(diffs.d)
+	if string(got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
@@ -11,7 +11,9 @@ func max(a, b int) int {
 	return a
 }
 
-func longestCommonSubsequence(first, second []*decode.JsonNode) []*decode.JsonNode {
+// longestCommonSubsequence 返回 first 和 second 的最长公共子序列，
+// equal 用来判断 first[i] 和 second[j] 是否相等
+func longestCommonSubsequence(first, second []*decode.JsonNode, equal func(i, j int) bool) []*decode.JsonNode {
 	line := len(first) + 1
 	column := len(second) + 1
 	if line == 1 || column == 1 {
@@ -23,7 +25,7 @@ func longestCommonSubsequence(first, second []*decode.JsonNode) []*decode.JsonNo
 	}
 	for i := 1; i < line; i++ {
 		for j := 1; j < column; j++ {
-			if first[i-1].Equal(second[j-1]) {
+			if equal(i-1, j-1) {
 				dp[i][j] = dp[i-1][j-1] + 1
 			} else {
 				dp[i][j] = max(dp[i-1][j], dp[i][j-1])
@@ -50,3 +52,46 @@ func longestCommonSubsequence(first, second []*decode.JsonNode) []*decode.JsonNo
 
 	return res
 }
+
+// lineEdit 是比较两组文本行得到的一个编辑操作，op 为 ' '、'-' 或 '+'，
+// a 和 b 分别是该操作之前已经处理过的 first 和 second 中的行数
+type lineEdit struct {
+	op   byte
+	line string
+	a, b int
+}
+
+// lineEdits 使用最长公共子序列比较 first 和 second 两组文本行，
+// 返回把 first 变为 second 的编辑操作，每一段修改中删除的行总是在添加的行之前
+func lineEdits(first, second []string) []lineEdit {
+	dp := make([][]int, len(first)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(second)+1)
+	}
+	for i := len(first) - 1; i >= 0; i-- {
+		for j := len(second) - 1; j >= 0; j-- {
+			if first[i] == second[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	res := make([]lineEdit, 0, len(first)+len(second))
+	i, j := 0, 0
+	for i < len(first) || j < len(second) {
+		switch {
+		case i < len(first) && j < len(second) && first[i] == second[j]:
+			res = append(res, lineEdit{op: ' ', line: first[i], a: i, b: j})
+			i++
+			j++
+		case j == len(second) || (i < len(first) && dp[i+1][j] >= dp[i][j+1]):
+			res = append(res, lineEdit{op: '-', line: first[i], a: i, b: j})
+			i++
+		default:
+			res = append(res, lineEdit{op: '+', line: second[j], a: i, b: j})
+			j++
+		}
+	}
+	return res
+}
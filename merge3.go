@@ -0,0 +1,347 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"strconv"
+)
+
+// MergeStrategy 决定 Merge3 如何处理冲突
+type MergeStrategy int
+
+const (
+	// MergeStrategyFail 有冲突时返回由 ErrMergeConflict 装饰的 error，这是默认的策略
+	MergeStrategyFail MergeStrategy = iota
+	// MergeStrategyOurs 有冲突时使用 ours 中的值
+	MergeStrategyOurs
+	// MergeStrategyTheirs 有冲突时使用 theirs 中的值
+	MergeStrategyTheirs
+)
+
+// Merge3Options 控制 Merge3 的行为
+type Merge3Options struct {
+	// Strategy 处理冲突的策略
+	Strategy MergeStrategy
+	// DiffOptions 计算差异时使用的选项，与 GetDiffNode 的选项相同，
+	// 如 UseToleranceOption、UseExcludeOption 等，被排除的路径上的修改会被忽略
+	DiffOptions []DiffOption
+}
+
+// Conflict 描述三方合并中的一个冲突，值为 nil 表示该节点在对应的文档中不存在。
+// 两方在数组的同一位置插入了不同的元素时，Path 为插入位置在 base 中的下标，
+// Base 为 nil，Ours 和 Theirs 为各自插入的元素组成的数组
+type Conflict struct {
+	Path   string
+	Base   *decode.JsonNode
+	Ours   *decode.JsonNode
+	Theirs *decode.JsonNode
+}
+
+// Merge3 以 base 为共同祖先合并 ours 和 theirs 两个文档，返回合并后的文档以及所有冲突，不会修改传入的文档。
+// 合并时先用与 GetDiffNode 相同的方式分别计算 base 到 ours 和 base 到 theirs 的差异，
+// 再把两组操作中的数组下标按 LCS 对齐的结果换算为 base 中的下标后按路径合并：
+// 两方修改的路径互不重叠时都会保留，如一方在数组末尾追加元素、另一方修改数组中的其他元素；
+// 两方修改了相同或互为祖先的路径，或在数组的同一位置插入了不同的元素时才是冲突，
+// 两方做了相同修改的节点以及两方都新增的对象（按 key 继续合并）不是冲突。
+// 使用 MergeStrategyFail（opts 为 nil 时的默认值）时，有冲突则返回 nil、冲突列表和由 ErrMergeConflict 装饰的 error；
+// 使用 MergeStrategyOurs 或 MergeStrategyTheirs 时冲突会使用对应一方的值解决，同时仍然返回冲突列表
+func Merge3(base, ours, theirs *decode.JsonNode, opts *Merge3Options) (*decode.JsonNode, []Conflict, error) {
+	if opts == nil {
+		opts = &Merge3Options{}
+	}
+	m := &merger{cfg: newDiffConfig(opts.DiffOptions), strategy: opts.Strategy}
+	res := m.merge(decode.Pointer{}, base, ours, theirs)
+	if len(m.conflicts) > 0 && m.strategy == MergeStrategyFail {
+		return nil, m.conflicts, errors.Wrapf(ErrMergeConflict, "%d conflict(s), first at %q",
+			len(m.conflicts), m.conflicts[0].Path)
+	}
+	return res, m.conflicts, nil
+}
+
+type merger struct {
+	cfg       *diffConfig
+	strategy  MergeStrategy
+	conflicts []Conflict
+}
+
+// edit 是把 base 修改为 ours 或 theirs 的一个操作，tokens 中的数组下标都是 base 中的下标。
+// insert 为 true 时表示在 tokens 的父节点（数组）中下标为最后一个 token 的元素之前插入 value，
+// 否则 remove 为 true 时删除 tokens 处的节点，为 false 时把 tokens 处的节点设为 value
+type edit struct {
+	tokens []string
+	insert bool
+	remove bool
+	value  *decode.JsonNode
+}
+
+// merge 合并 path 处的三个节点，返回 nil 表示合并后该节点不存在
+func (m *merger) merge(path decode.Pointer, base, ours, theirs *decode.JsonNode) *decode.JsonNode {
+	return m.resolve(path, base, m.edits(path, base, ours), m.edits(path, base, theirs))
+}
+
+// edits 比较 path 处的 base 和 node，把得到的操作转换为 edit。
+// 操作中的数组下标是依次执行前面的操作之后的位置，这里逐个模拟，换算为 base 中的下标；
+// 无法换算的操作（如比较器生成的 move）会使整组操作退化为把 path 处的节点替换为 node
+func (m *merger) edits(path decode.Pointer, base, node *decode.JsonNode) []edit {
+	diffs := newDiffs()
+	diff(diffs, path, base, node, m.cfg)
+	origins := make(map[string][]int)
+	res := make([]edit, 0, diffs.size())
+	for _, op := range diffs.d.Children {
+		e, ok := translateOp(path, base, op, origins)
+		if ok {
+			for _, prev := range res {
+				if !prev.insert && hasPrefix(e.tokens, prev.tokens) {
+					ok = false
+					break
+				}
+			}
+		}
+		if !ok {
+			return []edit{{tokens: path.Tokens(), remove: node == nil, value: node}}
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// translateOp 把 op 转换为 edit，origins 记录了每个数组中当前的元素在 base 中的下标，
+// 新插入的元素为 -1
+func translateOp(path decode.Pointer, base, op *decode.JsonNode, origins map[string][]int) (edit, bool) {
+	name, err := diffString(op, "op")
+	if err != nil || name != "add" && name != "remove" && name != "replace" {
+		return edit{}, false
+	}
+	s, err := diffString(op, "path")
+	if err != nil {
+		return edit{}, false
+	}
+	p, err := decode.ParsePointer(s)
+	if err != nil || !path.IsPrefixOf(p) {
+		return edit{}, false
+	}
+	tokens := p.Tokens()
+	at := path
+	cur := base
+	for k := len(path.Tokens()); k < len(tokens); k++ {
+		last := k == len(tokens)-1
+		switch {
+		case isType(cur, decode.JsonNodeTypeObject):
+			cur = cur.ChildrenMap[tokens[k]]
+			if cur == nil && (!last || name != "add") {
+				return edit{}, false
+			}
+		case isType(cur, decode.JsonNodeTypeSlice):
+			origin, ok := origins[at.String()]
+			if !ok {
+				origin = make([]int, len(cur.Children))
+				for i := range origin {
+					origin[i] = i
+				}
+			}
+			pos, err := strconv.Atoi(tokens[k])
+			if err != nil || pos < 0 || pos > len(origin) {
+				return edit{}, false
+			}
+			if last && name == "add" {
+				gap := len(cur.Children)
+				for _, o := range origin[pos:] {
+					if o >= 0 {
+						gap = o
+						break
+					}
+				}
+				inserted := make([]int, 0, len(origin)+1)
+				inserted = append(append(append(inserted, origin[:pos]...), -1), origin[pos:]...)
+				origins[at.String()] = inserted
+				tokens[k] = strconv.Itoa(gap)
+				return edit{tokens: tokens, insert: true, value: op.ChildrenMap["value"]}, true
+			}
+			if pos == len(origin) || origin[pos] < 0 {
+				return edit{}, false
+			}
+			tokens[k] = strconv.Itoa(origin[pos])
+			cur = cur.Children[origin[pos]]
+			if last && name == "remove" {
+				origin = append(origin[:pos:pos], origin[pos+1:]...)
+			}
+			origins[at.String()] = origin
+		default:
+			return edit{}, false
+		}
+		at = at.Append(tokens[k])
+	}
+	return edit{tokens: tokens, remove: name == "remove", value: op.ChildrenMap["value"]}, true
+}
+
+// resolve 把 ours 和 theirs 两组 edit 合并应用到 path 处的 base 上，
+// 两方都修改了 path 本身，或一方修改了 path、另一方修改了它的子节点时，比较两方的结果判断是否冲突
+func (m *merger) resolve(path decode.Pointer, base *decode.JsonNode, ours, theirs []edit) *decode.JsonNode {
+	if len(ours) == 0 && len(theirs) == 0 {
+		return cloneNode(base)
+	}
+	depth := len(path.Tokens())
+	if exact(ours, depth) != nil || exact(theirs, depth) != nil {
+		o, t := m.side(path, base, ours), m.side(path, base, theirs)
+		switch {
+		case len(theirs) == 0 || m.cfg.equal(path, o, t):
+			return o
+		case len(ours) == 0:
+			return t
+		case isType(o, decode.JsonNodeTypeObject) && isType(t, decode.JsonNodeTypeObject) &&
+			!isType(base, decode.JsonNodeTypeObject):
+			// 两方都新增了对象（或把其他类型的值改为对象）时按 key 继续合并
+			return m.merge(path, decode.NewObjectNode("", map[string]*decode.JsonNode{}, 0), o, t)
+		}
+		return m.conflict(path, base, o, t)
+	}
+	oursChildren, oursInserts := split(ours, depth)
+	theirsChildren, theirsInserts := split(theirs, depth)
+	switch {
+	case isType(base, decode.JsonNodeTypeObject):
+		keys := base.ObjectKeys()
+		for _, edits := range [][]edit{ours, theirs} {
+			for _, e := range edits {
+				key := e.tokens[depth]
+				if _, ok := base.ChildrenMap[key]; !ok && !contains(keys, key) {
+					keys = append(keys, key)
+				}
+			}
+		}
+		res := decode.NewObjectNode("", map[string]*decode.JsonNode{}, 0)
+		for _, key := range keys {
+			child := m.resolve(path.Append(key), base.ChildrenMap[key], oursChildren[key], theirsChildren[key])
+			if child != nil {
+				_ = res.ADD(key, child)
+			}
+		}
+		return res
+	case isType(base, decode.JsonNodeTypeSlice):
+		res := decode.NewSliceNode(make([]*decode.JsonNode, 0, len(base.Children)), 0)
+		for i := 0; i <= len(base.Children); i++ {
+			idx := strconv.Itoa(i)
+			res.Children = append(res.Children, m.resolveInserts(path.Append(idx), oursInserts[idx], theirsInserts[idx])...)
+			if i == len(base.Children) {
+				break
+			}
+			child := m.resolve(path.Append(idx), base.Children[i], oursChildren[idx], theirsChildren[idx])
+			if child != nil {
+				res.Children = append(res.Children, child)
+			}
+		}
+		return res
+	}
+	return cloneNode(base)
+}
+
+// side 返回只应用一方的 edits 后 path 处的节点
+func (m *merger) side(path decode.Pointer, base *decode.JsonNode, edits []edit) *decode.JsonNode {
+	if e := exact(edits, len(path.Tokens())); e != nil {
+		if e.remove {
+			return nil
+		}
+		return cloneNode(e.value)
+	}
+	return m.resolve(path, base, edits, nil)
+}
+
+// resolveInserts 合并两方在数组的同一位置（path）之前插入的元素
+func (m *merger) resolveInserts(path decode.Pointer, ours, theirs []*decode.JsonNode) []*decode.JsonNode {
+	o := decode.NewSliceNode(make([]*decode.JsonNode, 0, len(ours)), 0)
+	for _, node := range ours {
+		o.Children = append(o.Children, cloneNode(node))
+	}
+	t := decode.NewSliceNode(make([]*decode.JsonNode, 0, len(theirs)), 0)
+	for _, node := range theirs {
+		t.Children = append(t.Children, cloneNode(node))
+	}
+	if len(theirs) == 0 || m.cfg.equal(path, o, t) {
+		return o.Children
+	}
+	if len(ours) == 0 {
+		return t.Children
+	}
+	return m.conflict(path, nil, o, t).Children
+}
+
+// conflict 记录 path 处的冲突，并按照策略返回解决冲突后的节点
+func (m *merger) conflict(path decode.Pointer, base, ours, theirs *decode.JsonNode) *decode.JsonNode {
+	m.conflicts = append(m.conflicts, Conflict{
+		Path:   path.String(),
+		Base:   cloneNode(base),
+		Ours:   ours,
+		Theirs: theirs,
+	})
+	if m.strategy == MergeStrategyTheirs {
+		return cloneNode(theirs)
+	}
+	return cloneNode(ours)
+}
+
+// exact 返回 edits 中修改深度为 depth 的节点本身的最后一个 edit
+func exact(edits []edit, depth int) *edit {
+	var res *edit
+	for i := range edits {
+		if !edits[i].insert && len(edits[i].tokens) == depth {
+			res = &edits[i]
+		}
+	}
+	return res
+}
+
+// split 按深度为 depth 的 token 对 edits 分组，在该层数组中插入元素的 edit 单独分组
+func split(edits []edit, depth int) (map[string][]edit, map[string][]*decode.JsonNode) {
+	children := make(map[string][]edit)
+	inserts := make(map[string][]*decode.JsonNode)
+	for _, e := range edits {
+		token := e.tokens[depth]
+		if e.insert && len(e.tokens) == depth+1 {
+			inserts[token] = append(inserts[token], e.value)
+		} else {
+			children[token] = append(children[token], e)
+		}
+	}
+	return children, inserts
+}
+
+func hasPrefix(tokens, prefix []string) bool {
+	if len(prefix) > len(tokens) {
+		return false
+	}
+	for i, token := range prefix {
+		if tokens[i] != token {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isType(node *decode.JsonNode, t decode.JsonNodeType) bool {
+	return node != nil && node.Type == t
+}
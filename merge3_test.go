@@ -0,0 +1,214 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		opts      *Merge3Options
+		want      string
+		conflicts []string
+	}{
+		{
+			name:   "non-overlapping",
+			base:   `{"a": 1, "b": {"c": 1, "d": 1}, "e": 1}`,
+			ours:   `{"a": 2, "b": {"c": 2, "d": 1}, "e": 1}`,
+			theirs: `{"a": 1, "b": {"c": 1, "d": 2}, "f": 1}`,
+			want:   `{"a":2,"b":{"c":2,"d":2},"f":1}`,
+		},
+		{
+			name:   "same change",
+			base:   `{"a": 1, "b": [1]}`,
+			ours:   `{"a": 2, "b": [1, 2]}`,
+			theirs: `{"a": 2, "b": [1, 2]}`,
+			want:   `{"a":2,"b":[1,2]}`,
+		},
+		{
+			name:   "both add object",
+			base:   `{}`,
+			ours:   `{"x": {"a": 1, "c": 1}}`,
+			theirs: `{"x": {"b": 1, "c": 1}}`,
+			want:   `{"x":{"a":1,"c":1,"b":1}}`,
+		},
+		{
+			name:   "same length arrays",
+			base:   `[{"a": 1}, 2, 3]`,
+			ours:   `[{"a": 2}, 2, 4]`,
+			theirs: `[{"a": 1, "b": 1}, 5, 3]`,
+			want:   `[{"a":2,"b":1},5,4]`,
+		},
+		{
+			name:      "value conflict",
+			base:      `{"a": 1, "b": 1}`,
+			ours:      `{"a": 2, "b": 2}`,
+			theirs:    `{"a": 3, "b": 1}`,
+			conflicts: []string{"/a"},
+		},
+		{
+			name:      "ours strategy",
+			base:      `{"a": 1, "b": 1}`,
+			ours:      `{"a": 2, "b": 2}`,
+			theirs:    `{"a": 3, "b": 1}`,
+			opts:      &Merge3Options{Strategy: MergeStrategyOurs},
+			want:      `{"a":2,"b":2}`,
+			conflicts: []string{"/a"},
+		},
+		{
+			name:      "theirs strategy",
+			base:      `{"a": 1, "b": 1}`,
+			ours:      `{"a": 2, "b": 2}`,
+			theirs:    `{"a": 3, "b": 1}`,
+			opts:      &Merge3Options{Strategy: MergeStrategyTheirs},
+			want:      `{"a":3,"b":2}`,
+			conflicts: []string{"/a"},
+		},
+		{
+			name:      "remove and modify",
+			base:      `{"a": {"b": 1}, "c": 1}`,
+			ours:      `{"c": 1}`,
+			theirs:    `{"a": {"b": 2}, "c": 1}`,
+			opts:      &Merge3Options{Strategy: MergeStrategyOurs},
+			want:      `{"c":1}`,
+			conflicts: []string{"/a"},
+		},
+		{
+			name:   "append and edit",
+			base:   `{"a": [1, 2]}`,
+			ours:   `{"a": [1, 2, 3]}`,
+			theirs: `{"a": [0, 2]}`,
+			want:   `{"a":[0,2,3]}`,
+		},
+		{
+			name:   "inserts at different positions",
+			base:   `[1, 2, 3]`,
+			ours:   `[0, 1, 2, 3]`,
+			theirs: `[1, 2, 2.5, 3, 4]`,
+			want:   `[0,1,2,2.5,3,4]`,
+		},
+		{
+			name:   "remove and edit in the same array",
+			base:   `[{"id": 1, "v": 1}, {"id": 2, "v": 1}, {"id": 3, "v": 1}]`,
+			ours:   `[{"id": 2, "v": 1}, {"id": 3, "v": 1}]`,
+			theirs: `[{"id": 1, "v": 1}, {"id": 2, "v": 1}, {"id": 3, "v": 2}]`,
+			want:   `[{"id":2,"v":1},{"id":3,"v":2}]`,
+		},
+		{
+			name:   "remove different elements",
+			base:   `[1, 2, 3, 4]`,
+			ours:   `[2, 3, 4]`,
+			theirs: `[1, 2, 3]`,
+			want:   `[2,3]`,
+		},
+		{
+			name:      "inserts at the same position",
+			base:      `{"a": [1, 2]}`,
+			ours:      `{"a": [1, 3, 2]}`,
+			theirs:    `{"a": [1, 4, 2], "b": 1}`,
+			opts:      &Merge3Options{Strategy: MergeStrategyTheirs},
+			want:      `{"a":[1,4,2],"b":1}`,
+			conflicts: []string{"/a/1"},
+		},
+		{
+			name:      "remove and edit the same element",
+			base:      `{"a": [1, {"b": 1}, 3]}`,
+			ours:      `{"a": [1, 3, 4]}`,
+			theirs:    `{"a": [0, 1, {"b": 2}, 3]}`,
+			opts:      &Merge3Options{Strategy: MergeStrategyOurs},
+			want:      `{"a":[0,1,3,4]}`,
+			conflicts: []string{"/a/1"},
+		},
+		{
+			name:   "tolerance",
+			base:   `{"a": 1.0, "b": 1}`,
+			ours:   `{"a": 1.0000001, "b": 1}`,
+			theirs: `{"a": 1.0, "b": 2}`,
+			opts: &Merge3Options{DiffOptions: []DiffOption{
+				UseToleranceOption(1e-3, 0),
+			}},
+			want: `{"a":1.0,"b":2}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, _ := decode.Unmarshal([]byte(tt.base))
+			ours, _ := decode.Unmarshal([]byte(tt.ours))
+			theirs, _ := decode.Unmarshal([]byte(tt.theirs))
+			got, conflicts, err := Merge3(base, ours, theirs, tt.opts)
+			if len(conflicts) != len(tt.conflicts) {
+				t.Fatalf("want conflicts %v, got %v", tt.conflicts, conflicts)
+			}
+			for i, c := range conflicts {
+				if c.Path != tt.conflicts[i] {
+					t.Errorf("want conflict at %s, got %s", tt.conflicts[i], c.Path)
+				}
+			}
+			if tt.want == "" {
+				if !errors.Is(err, ErrMergeConflict) || got != nil {
+					t.Errorf("want ErrMergeConflict, got %v, %s", err, node2string(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if s := node2string(got); s != tt.want {
+				t.Errorf("want %s, got %s", tt.want, s)
+			}
+		})
+	}
+}
+
+func TestMerge3_conflictValues(t *testing.T) {
+	base, _ := decode.Unmarshal([]byte(`{"a": 1}`))
+	ours, _ := decode.Unmarshal([]byte(`{}`))
+	theirs, _ := decode.Unmarshal([]byte(`{"a": 2}`))
+	_, conflicts, _ := Merge3(base, ours, theirs, nil)
+	if len(conflicts) != 1 {
+		t.Fatalf("want 1 conflict, got %d", len(conflicts))
+	}
+	c := conflicts[0]
+	if node2string(c.Base) != "1" || c.Ours != nil || node2string(c.Theirs) != "2" {
+		t.Errorf("unexpected conflict %s %v %s", node2string(c.Base), c.Ours, node2string(c.Theirs))
+	}
+	if ours.ChildrenMap["a"] != nil || node2string(theirs) != `{"a":2}` {
+		t.Errorf("the inputs were modified")
+	}
+}
+
+func TestMerge3_insertConflict(t *testing.T) {
+	base, _ := decode.Unmarshal([]byte(`[1, 2]`))
+	ours, _ := decode.Unmarshal([]byte(`[1, 3, 2]`))
+	theirs, _ := decode.Unmarshal([]byte(`[1, 4, 5, 2]`))
+	_, conflicts, _ := Merge3(base, ours, theirs, nil)
+	if len(conflicts) != 1 {
+		t.Fatalf("want 1 conflict, got %d", len(conflicts))
+	}
+	c := conflicts[0]
+	if c.Path != "/1" || c.Base != nil || node2string(c.Ours) != "[3]" || node2string(c.Theirs) != "[4,5]" {
+		t.Errorf("unexpected conflict %s %v %s %s", c.Path, c.Base, node2string(c.Ours), node2string(c.Theirs))
+	}
+}
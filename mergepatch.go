@@ -0,0 +1,184 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+)
+
+// GetMergePatchNode 比较 src 和 dst，返回把 src 变为 dst 的 JSON Merge Patch (RFC 7396)。
+// Merge Patch 使用 null 表示删除，因此当 dst 中某个字段的值被设置为 null，
+// 或者需要整体写入的对象中包含值为 null 的字段时无法表示，此时返回由 ErrMergePatchUnrepresentable 装饰的 error
+func GetMergePatchNode(src, dst *decode.JsonNode) (*decode.JsonNode, error) {
+	if dst == nil {
+		return nil, errors.New("dst is nil")
+	}
+	return mergePatch(decode.Pointer{}, src, dst)
+}
+
+// AsMergePatch 比较 source 和 patch 两个 JSON 文档，并返回 JSON Merge Patch 格式的差异
+func AsMergePatch(source, patch []byte) ([]byte, error) {
+	srcNode, err := decode.Unmarshal(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to unmarshal source data")
+	}
+	dstNode, err := decode.Unmarshal(patch)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to unmarshal patch data")
+	}
+	res, err := GetMergePatchNode(srcNode, dstNode)
+	if err != nil {
+		return nil, err
+	}
+	return decode.Marshal(res)
+}
+
+// ApplyMergePatchNode 按照 RFC 7396 把 patch 应用于 source 上，并返回合并后的新 JsonNode 对象，不会修改 source。
+// patch 是对象时递归合并，值为 null 的字段会被删除；否则 patch 会替换整个文档。source 可以为 nil
+func ApplyMergePatchNode(source, patch *decode.JsonNode) (*decode.JsonNode, error) {
+	if patch == nil {
+		return source, nil
+	}
+	var target *decode.JsonNode
+	if source != nil {
+		copyNode, err := DeepCopy(source)
+		if err != nil {
+			return nil, errors.Wrap(err, "fail to deep copy source")
+		}
+		target = copyNode
+	}
+	return applyMergePatch(target, patch)
+}
+
+// ApplyMergePatch 把 JSON Merge Patch 格式的 patch 应用于 source 上
+func ApplyMergePatch(source, patch []byte) ([]byte, error) {
+	patchNode, err := decode.Unmarshal(patch)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to unmarshal patch data")
+	}
+	srcNode, err := decode.Unmarshal(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to unmarshal source data")
+	}
+	res, err := ApplyMergePatchNode(srcNode, patchNode)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to merge patch")
+	}
+	return decode.Marshal(res)
+}
+
+func mergePatch(path decode.Pointer, src, dst *decode.JsonNode) (*decode.JsonNode, error) {
+	if src == nil || src.Type != decode.JsonNodeTypeObject || dst.Type != decode.JsonNodeTypeObject {
+		return replaceWithMergePatch(path, dst)
+	}
+	patch := decode.NewObjectNode("", map[string]*decode.JsonNode{}, 0)
+	for _, key := range src.ObjectKeys() {
+		srcValue := src.ChildrenMap[key]
+		dstValue, ok := dst.ChildrenMap[key]
+		if ok && srcValue.Equal(dstValue) {
+			continue
+		}
+		var value *decode.JsonNode
+		var err error
+		if !ok {
+			value = decode.NewValueNode(nil, 0)
+		} else {
+			value, err = mergePatch(path.Append(key), srcValue, dstValue)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := patch.ADD(key, value); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range dst.ObjectKeys() {
+		if _, ok := src.ChildrenMap[key]; ok {
+			continue
+		}
+		value, err := replaceWithMergePatch(path.Append(key), dst.ChildrenMap[key])
+		if err != nil {
+			return nil, err
+		}
+		if err := patch.ADD(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return patch, nil
+}
+
+// replaceWithMergePatch 返回把 path 处的节点整体替换为 dst 的 Merge Patch
+func replaceWithMergePatch(path decode.Pointer, dst *decode.JsonNode) (*decode.JsonNode, error) {
+	if !path.IsRoot() && isNull(dst) {
+		return nil, errors.Wrapf(ErrMergePatchUnrepresentable, "cannot set %s to null", path)
+	}
+	if p, ok := findNull(path, dst); ok {
+		return nil, errors.Wrapf(ErrMergePatchUnrepresentable, "cannot set %s to null", p)
+	}
+	return DeepCopy(dst)
+}
+
+// findNull 返回 node 中第一个值为 null 的字段，数组会被整体替换，因此不检查数组中的元素
+func findNull(path decode.Pointer, node *decode.JsonNode) (decode.Pointer, bool) {
+	if node.Type != decode.JsonNodeTypeObject {
+		return decode.Pointer{}, false
+	}
+	for _, key := range node.ObjectKeys() {
+		child := node.ChildrenMap[key]
+		if isNull(child) {
+			return path.Append(key), true
+		}
+		if p, ok := findNull(path.Append(key), child); ok {
+			return p, true
+		}
+	}
+	return decode.Pointer{}, false
+}
+
+func applyMergePatch(target, patch *decode.JsonNode) (*decode.JsonNode, error) {
+	if patch.Type != decode.JsonNodeTypeObject {
+		return DeepCopy(patch)
+	}
+	if target == nil || target.Type != decode.JsonNodeTypeObject {
+		target = decode.NewObjectNode("", map[string]*decode.JsonNode{}, 0)
+	}
+	for _, key := range patch.ObjectKeys() {
+		value := patch.ChildrenMap[key]
+		if isNull(value) {
+			if _, ok := target.ChildrenMap[key]; ok {
+				if _, err := target.Remove(key); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		child, err := applyMergePatch(target.ChildrenMap[key], value)
+		if err != nil {
+			return nil, err
+		}
+		if err := target.ADD(key, child); err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+func isNull(node *decode.JsonNode) bool {
+	return node != nil && node.Type == decode.JsonNodeTypeValue && node.Value == nil
+}
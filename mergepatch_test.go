@@ -0,0 +1,137 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+// RFC 7396 Appendix A
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		src, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		got, err := ApplyMergePatch([]byte(tt.src), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("ApplyMergePatch(%s, %s) got an error: %v", tt.src, tt.patch, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("ApplyMergePatch(%s, %s) = %s, want %s", tt.src, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestApplyMergePatchNode_source(t *testing.T) {
+	src, _ := decode.Unmarshal([]byte(`{"a": {"b": 1}}`))
+	patch, _ := decode.Unmarshal([]byte(`{"a": {"b": null, "c": 2}}`))
+	res, err := ApplyMergePatchNode(src, patch)
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	if got := node2string(res); got != `{"a":{"c":2}}` {
+		t.Errorf("want {\"a\":{\"c\":2}}, got %s", got)
+	}
+	if got := node2string(src); got != `{"a":{"b":1}}` {
+		t.Errorf("source should not be modified, got %s", got)
+	}
+	res, err = ApplyMergePatchNode(nil, patch)
+	if err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	if got := node2string(res); got != `{"a":{"c":2}}` {
+		t.Errorf("want {\"a\":{\"c\":2}}, got %s", got)
+	}
+}
+
+func TestAsMergePatch(t *testing.T) {
+	tests := []struct {
+		src, dst, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b","b":"c"}`, `{"b":"c"}`, `{"a":null}`},
+		{`{"a":{"b":"c","d":1},"e":[1,2]}`, `{"a":{"b":"d","d":1},"e":[1],"f":{"g":true}}`,
+			`{"a":{"b":"d"},"e":[1],"f":{"g":true}}`},
+		{`{"a":[{"b":1}]}`, `{"a":[{"b":null}]}`, `{"a":[{"b":null}]}`},
+		{`{"a":1}`, `{"a":1}`, `{}`},
+		{`{"a":1}`, `[1]`, `[1]`},
+		{`{"a":1}`, `null`, `null`},
+		{`[1]`, `{"a":1}`, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		got, err := AsMergePatch([]byte(tt.src), []byte(tt.dst))
+		if err != nil {
+			t.Errorf("AsMergePatch(%s, %s) got an error: %v", tt.src, tt.dst, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("AsMergePatch(%s, %s) = %s, want %s", tt.src, tt.dst, got, tt.want)
+		}
+		res, err := ApplyMergePatch([]byte(tt.src), got)
+		if err != nil {
+			t.Fatalf("got an error: %v", err)
+		}
+		a, _ := decode.Unmarshal(res)
+		b, _ := decode.Unmarshal([]byte(tt.dst))
+		if !a.Equal(b) {
+			t.Errorf("apply %s to %s = %s, want %s", got, tt.src, res, tt.dst)
+		}
+	}
+}
+
+func TestAsMergePatch_unrepresentable(t *testing.T) {
+	tests := []struct {
+		src, dst string
+	}{
+		{`{"a":1}`, `{"a":null}`},
+		{`{}`, `{"a":null}`},
+		{`{"a":{"b":1}}`, `{"a":{"b":1,"c":{"d":null}}}`},
+		{`{"a":1}`, `{"a":{"b":null}}`},
+		{`[]`, `{"a":null}`},
+	}
+	for _, tt := range tests {
+		_, err := AsMergePatch([]byte(tt.src), []byte(tt.dst))
+		if !errors.Is(err, ErrMergePatchUnrepresentable) {
+			t.Errorf("AsMergePatch(%s, %s) want ErrMergePatchUnrepresentable, got %v", tt.src, tt.dst, err)
+		}
+	}
+}
+
+func node2string(node *decode.JsonNode) string {
+	b, _ := decode.Marshal(node)
+	return string(b)
+}
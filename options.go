@@ -1,12 +1,48 @@
 package json_diff
 
 import (
-	"fmt"
 	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"strconv"
 )
 
+// DiffOption 是 GetDiffNode、AsDiffs、MergeDiff 等函数的选项，
+// JsonDiffOption 以及 UseArrayKeyOption 等函数的返回值都实现了该接口。
+// 这些函数的可变参数以前是 ...JsonDiffOption，展开 []JsonDiffOption 的调用需要改为 []DiffOption
+type DiffOption interface {
+	apply(cfg *diffConfig)
+}
+
+// diffConfig 保存所有选项合并后的结果
+type diffConfig struct {
+	flags        JsonDiffOption
+	arrayKeys    []arrayKeyOption
+	unorderedAll bool
+	unorderedSet [][]string
+	hasInclude   bool
+	includes     [][]string
+	excludes     [][]string
+
+	pathComparators []comparatorOption
+	typeComparators []comparatorOption
+	tolerances      []toleranceOption
+}
+
+func newDiffConfig(options []DiffOption) *diffConfig {
+	cfg := &diffConfig{}
+	for _, o := range options {
+		if o != nil {
+			o.apply(cfg)
+		}
+	}
+	return cfg
+}
+
 type JsonDiffOption uint
 
+func (o JsonDiffOption) apply(cfg *diffConfig) {
+	cfg.flags |= o
+}
+
 const (
 	// UseCopyOption 返回差异时使用 Copy, 当发现新增的子串出现在原串中时，使用该选项可以将 Add 行为替换为 Copy 行为
 	// 以减少差异串的大小，但这需要额外的计算，默认不开启
@@ -21,8 +57,112 @@ const (
 
 	// UseFullRemoveOption Remove 时除了返回 path, 还返回删除了的值，默认不开启
 	UseFullRemoveOption
+
+	// UseSortedPathOption 比较对象时按 key 的字典序而不是文档中的顺序输出差异，
+	// 数组中元素的差异总是按下标顺序输出，以保证下标的正确性，默认不开启
+	UseSortedPathOption
+
+	// UseLenientMergeOption 仅用于 MergeDiff 和 MergeDiffNode，按照兼容旧版本的宽松规则应用差异：
+	// add 的数组下标越界时追加到末尾，replace 不存在的 key 时直接添加，
+	// move 时先在 path 处替换或添加，再删除 from 处的节点。默认严格遵循 RFC 6902
+	UseLenientMergeOption
+
+	// UseTestGuardOption 用于 GetDiffNode 时，在每一个 replace、remove 和 move 操作之前添加一个 test 操作，
+	// 确保被修改的节点仍然是原来的值；用于 InvertPatch 和 InvertPatchNode 时，在撤销每一个操作之前添加一个 test 操作，
+	// 确保被撤销的节点仍然是应用差异后的值，撤销 remove 时不会添加 test。默认不开启
+	UseTestGuardOption
 )
 
+// arrayKeyOption 指定匹配 pattern 的数组元素使用 key 字段作为身份标识
+type arrayKeyOption struct {
+	pattern []string
+	key     string
+}
+
+func (o arrayKeyOption) apply(cfg *diffConfig) {
+	if o.pattern != nil {
+		cfg.arrayKeys = append(cfg.arrayKeys, o)
+	}
+}
+
+// UseArrayKeyOption 比较数组时，对路径匹配 pattern 的数组元素使用它的 key 字段作为身份标识，
+// 而不是按照最长公共子序列对齐：key 相同的元素会被递归比较，顺序变化时使用 Move 表示。
+// pattern 是一个 JSON Pointer，其中的 "*" 可以匹配任意一个 token，最后一个 token 对应数组元素，
+// 如 UseArrayKeyOption("/spec/containers/*", "name")；
+// pattern 不是合法的 JSON Pointer 或者是 "" 时 panic。
+// 只有数组中所有元素都是对象，且 key 字段都是唯一的非容器类型值时才会按 key 比较，否则仍然按最长公共子序列比较
+func UseArrayKeyOption(pattern, key string) DiffOption {
+	tokens := mustParsePattern(pattern)
+	if len(tokens) == 0 {
+		panic(`json_diff: the pattern of UseArrayKeyOption must not be ""`)
+	}
+	return arrayKeyOption{pattern: tokens, key: key}
+}
+
+// arrayKey 返回 path 处的数组元素使用的身份标识字段
+func (cfg *diffConfig) arrayKey(path decode.Pointer) (string, bool) {
+	tokens := path.Tokens()
+	for _, o := range cfg.arrayKeys {
+		if len(o.pattern) == len(tokens)+1 && matchPattern(o.pattern[:len(tokens)], tokens) {
+			return o.key, true
+		}
+	}
+	return "", false
+}
+
+// unorderedOption 指定匹配 patterns 的数组为无序数组
+type unorderedOption struct {
+	all      bool
+	patterns [][]string
+}
+
+func (o unorderedOption) apply(cfg *diffConfig) {
+	cfg.unorderedAll = cfg.unorderedAll || o.all
+	cfg.unorderedSet = append(cfg.unorderedSet, o.patterns...)
+}
+
+// UseUnorderedArrayOption 把数组当作无序的多重集合比较：元素的顺序不影响比较结果，
+// 差异中只包含真正被添加或删除的元素，重复元素按出现次数计算。
+// 不传 patterns 时所有数组都是无序的，否则只有路径匹配某个 pattern 的数组是无序的，
+// pattern 是一个 JSON Pointer，其中的 "*" 可以匹配任意一个 token，如 UseUnorderedArrayOption("/tags", "/items/*/labels")；
+// pattern 不是合法的 JSON Pointer 时 panic。该选项同样作用于 Equal
+func UseUnorderedArrayOption(patterns ...string) DiffOption {
+	if len(patterns) == 0 {
+		return unorderedOption{all: true}
+	}
+	return unorderedOption{patterns: parsePatterns(patterns)}
+}
+
+// unordered 判断 path 处的数组是否是无序的
+func (cfg *diffConfig) unordered(path decode.Pointer) bool {
+	if cfg.unorderedAll {
+		return true
+	}
+	if len(cfg.unorderedSet) == 0 {
+		return false
+	}
+	tokens := path.Tokens()
+	for _, pattern := range cfg.unorderedSet {
+		if matchPattern(pattern, tokens) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern 判断 tokens 是否匹配 pattern，pattern 中的 "*" 可以匹配任意一个 token
+func matchPattern(pattern, tokens []string) bool {
+	if len(pattern) != len(tokens) {
+		return false
+	}
+	for i, p := range pattern {
+		if p != "*" && p != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func doOption(diffs *diffs, opt JsonDiffOption, src, target *decode.JsonNode) {
 	if diffs.d.Type != decode.JsonNodeTypeSlice {
 		return
@@ -37,6 +177,43 @@ func doOption(diffs *diffs, opt JsonDiffOption, src, target *decode.JsonNode) {
 	if opt&UseMoveOption == UseMoveOption {
 		doMoveOption(diffs, opt, src, target)
 	}
+	if opt&UseTestGuardOption == UseTestGuardOption {
+		doTestGuardOption(diffs, opt, src)
+	}
+}
+
+// doTestGuardOption 在 src 的拷贝上依次应用差异，并在每一个 replace、remove 和 move 操作之前
+// 添加 test 操作，test 的值是应用之前的差异后 path（move 为 from）处的值。
+// 某个差异应用失败时无法再知道之后的操作执行前的值，之后的操作不再添加 test
+func doTestGuardOption(diffs *diffs, opt JsonDiffOption, src *decode.JsonNode) {
+	work, err := DeepCopy(src)
+	if err != nil {
+		return
+	}
+	cfg := newDiffConfig(nil)
+	res := make([]*decode.JsonNode, 0, 2*diffs.size())
+	for _, op := range diffs.d.Children {
+		name, _ := diffString(op, "op")
+		key := "path"
+		if name == "move" {
+			key = "from"
+		}
+		if work != nil && (name == "replace" || name == "remove" || name == "move") {
+			path, _ := diffString(op, key)
+			if p, err := decode.ParsePointer(path); err == nil {
+				if value, ok := work.FindPointer(p); ok {
+					res = append(res, newDiffNode(DiffTypeTest, path, cloneNode(value), "", opt))
+				}
+			}
+		}
+		if work != nil {
+			if err := mergeOne(work, cloneNode(op), cfg); err != nil {
+				work = nil
+			}
+		}
+		res = append(res, op)
+	}
+	diffs.d.Children = res
 }
 
 func doCopyOption(diffs *diffs, opt JsonDiffOption, src, target *decode.JsonNode) {
@@ -191,13 +368,13 @@ func getUnChangeNodes(src *decode.JsonNode, target *decode.JsonNode) unChangeCon
 	contains := unChangeContainer{
 		c: make(map[string][]*unChangeContainerValue),
 	}
-	computeUnChangeNode(&contains, "", src, target)
+	computeUnChangeNode(&contains, decode.Pointer{}, src, target)
 	return contains
 }
 
-func computeUnChangeNode(container *unChangeContainer, path string, src, target *decode.JsonNode) {
+func computeUnChangeNode(container *unChangeContainer, path decode.Pointer, src, target *decode.JsonNode) {
 	if src.Equal(target) {
-		_, _ = container.storeOrLoad(src.Hash, path, src)
+		_, _ = container.storeOrLoad(src.Hash, path.String(), src)
 		return
 	}
 	if src.Type == target.Type {
@@ -210,19 +387,20 @@ func computeUnChangeNode(container *unChangeContainer, path string, src, target
 	}
 }
 
-func computeSliceUnChange(contains *unChangeContainer, path string, src, target *decode.JsonNode) {
+func computeSliceUnChange(contains *unChangeContainer, path decode.Pointer, src, target *decode.JsonNode) {
 	for i, v := range src.Children {
 		if i >= len(target.Children) {
 			return
 		}
-		computeUnChangeNode(contains, fmt.Sprintf("%s/%d", path, i), v, target.Children[i])
+		computeUnChangeNode(contains, path.Append(strconv.Itoa(i)), v, target.Children[i])
 	}
 }
 
-func computeObjectUnChange(contains *unChangeContainer, path string, src, target *decode.JsonNode) {
-	for k, v := range src.ChildrenMap {
+func computeObjectUnChange(contains *unChangeContainer, path decode.Pointer, src, target *decode.JsonNode) {
+	for _, k := range src.ObjectKeys() {
+		v := src.ChildrenMap[k]
 		if tarV, ok := target.ChildrenMap[k]; ok {
-			computeUnChangeNode(contains, fmt.Sprintf("%s/%s", path, k), v, tarV)
+			computeUnChangeNode(contains, path.Append(k), v, tarV)
 		}
 	}
 }
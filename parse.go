@@ -1,9 +1,12 @@
 package json_diff
 
 import (
+	"bytes"
 	"encoding/json"
 	"github.com/520MianXiangDuiXiang520/json-diff/decode"
 	"github.com/pkg/errors"
+	"io"
+	"sort"
 )
 
 func parse(v interface{}, level int64) *decode.JsonNode {
@@ -11,14 +14,17 @@ func parse(v interface{}, level int64) *decode.JsonNode {
 	switch v.(type) {
 	case map[string]interface{}:
 		value := v.(map[string]interface{})
-		root = &decode.JsonNode{Type: decode.JsonNodeTypeObject, Level: level, ChildrenMap: make(map[string]*decode.JsonNode)}
-		for key, va := range value {
-			key = decode.KeyReplace(key)
-			n := parse(va, level+1)
+		root = decode.NewObjectNode("", make(map[string]*decode.JsonNode, len(value)), int(level))
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		// encoding/json 不保留 key 的顺序，按字典序排列以保证结果稳定
+		sort.Strings(keys)
+		for _, key := range keys {
+			n := parse(value[key], level+1)
 			n.Key = key
-			// n.Father = root
-			root.ChildrenMap[key] = n
-			// root.Children = append(root.Children, n)
+			_ = root.ADD(key, n)
 		}
 	case []interface{}:
 		root = &decode.JsonNode{Type: decode.JsonNodeTypeSlice, Level: level}
@@ -28,6 +34,9 @@ func parse(v interface{}, level int64) *decode.JsonNode {
 			// n.Father = root
 			root.Children = append(root.Children, n)
 		}
+	case json.Number:
+		root = &decode.JsonNode{Type: decode.JsonNodeTypeValue, Level: level}
+		root.Value = decode.Number(v.(json.Number))
 	default:
 		root = &decode.JsonNode{Type: decode.JsonNodeTypeValue, Level: level}
 		root.Type = decode.JsonNodeTypeValue
@@ -39,13 +48,21 @@ func parse(v interface{}, level int64) *decode.JsonNode {
 // Parse 于 Unmarshal 无异
 func Parse(input []byte) (*decode.JsonNode, error) {
 	var v interface{}
-	if err := json.Unmarshal(input, &v); err != nil {
+	d := json.NewDecoder(bytes.NewReader(input))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
 		return nil, errors.Wrap(err, "fail to unmarshal")
 	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("fail to unmarshal: invalid character after top-level value")
+	}
 	return parse(v, 0), nil
 }
 
 func marshalValue(root *decode.JsonNode) interface{} {
+	if n, ok := root.Value.(decode.Number); ok {
+		return json.Number(n)
+	}
 	return root.Value
 }
 
@@ -0,0 +1,310 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"fmt"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const (
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+	colorReset = "\x1b[0m"
+)
+
+// RenderOptions 控制 RenderUnified 和 RenderPatch 的输出格式
+type RenderOptions struct {
+	// Context 每一段差异前后显示的未修改的行数
+	Context int
+	// Color 使用 ANSI 颜色标记删除（红色）和新增（绿色）的行
+	Color bool
+	// SideBySide 左右并排显示源文档和目标文档，而不是输出统一格式
+	SideBySide bool
+	// Width 并排显示时每一栏的宽度（按终端显示的列数计算，中日韩等宽字符占两列），
+	// 超出的部分会被截断，小于等于 0 时使用 60
+	Width int
+	// Indent 格式化 JSON 时使用的缩进，为空时使用两个空格
+	Indent string
+	// FromName 和 ToName 是文件头中源文档和目标文档的名字，为空时使用 a 和 b
+	FromName, ToName string
+}
+
+// DefaultRenderOptions 返回默认的输出格式：统一格式，3 行上下文，不使用颜色
+func DefaultRenderOptions() *RenderOptions {
+	return &RenderOptions{Context: 3}
+}
+
+// RenderUnified 把 src 和 dst 格式化后逐行比较，以类似 git diff 的统一格式写入 w，
+// 删除的行以 - 开头，新增的行以 + 开头；opts 为 nil 时使用 DefaultRenderOptions。
+// src 或 dst 为 nil 时视为空文档，两者相同时不输出任何内容
+func RenderUnified(w io.Writer, src, dst *decode.JsonNode, opts *RenderOptions) error {
+	if opts == nil {
+		opts = DefaultRenderOptions()
+	}
+	indent := opts.Indent
+	if indent == "" {
+		indent = "  "
+	}
+	a, err := renderLines(src, indent)
+	if err != nil {
+		return err
+	}
+	b, err := renderLines(dst, indent)
+	if err != nil {
+		return err
+	}
+	hunks := splitHunks(lineEdits(a, b), opts.Context)
+	if len(hunks) == 0 {
+		return nil
+	}
+	r := &renderer{w: w, opts: opts}
+	if opts.SideBySide {
+		r.sideBySide(hunks)
+	} else {
+		r.unified(hunks)
+	}
+	return r.err
+}
+
+// RenderPatch 把差异 patch 应用到 src 上，并以 RenderUnified 的格式输出 src 与应用后的结果之间的差异
+func RenderPatch(w io.Writer, src, patch *decode.JsonNode, opts *RenderOptions) error {
+	dst, err := MergeDiffNode(src, patch)
+	if err != nil {
+		return err
+	}
+	return RenderUnified(w, src, dst, opts)
+}
+
+// renderLines 格式化 node 并按行拆分
+func renderLines(node *decode.JsonNode, indent string) ([]string, error) {
+	if node == nil {
+		return nil, nil
+	}
+	b, err := decode.MarshalIndent(node, "", indent)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return strings.Split(string(b), "\n"), nil
+}
+
+// splitHunks 把编辑操作分成若干段，每段包含修改的行以及前后 context 行未修改的行，
+// 两段修改之间未修改的行不超过 2*context 时合并为一段
+func splitHunks(edits []lineEdit, context int) [][]lineEdit {
+	if context < 0 {
+		context = 0
+	}
+	var hunks [][]lineEdit
+	for i := 0; i < len(edits); i++ {
+		if edits[i].op == ' ' {
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		last := i
+		for j := i + 1; j < len(edits) && j-last-1 <= 2*context; j++ {
+			if edits[j].op != ' ' {
+				last = j
+			}
+		}
+		end := last + context + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+		hunks = append(hunks, edits[start:end])
+		i = last
+	}
+	return hunks
+}
+
+// hunkHeader 返回一段差异的头部，如 @@ -1,4 +1,5 @@
+func hunkHeader(hunk []lineEdit) string {
+	aCount, bCount := 0, 0
+	for _, e := range hunk {
+		if e.op != '+' {
+			aCount++
+		}
+		if e.op != '-' {
+			bCount++
+		}
+	}
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(hunk[0].a, aCount), hunkRange(hunk[0].b, bCount))
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// renderer 把差异写入 w，并记录第一个写入错误
+type renderer struct {
+	w    io.Writer
+	opts *RenderOptions
+	err  error
+}
+
+func (r *renderer) line(color, s string) {
+	if r.err != nil {
+		return
+	}
+	if r.opts.Color && color != "" {
+		s = color + s + colorReset
+	}
+	_, r.err = io.WriteString(r.w, s+"\n")
+}
+
+func (r *renderer) names() (string, string) {
+	from, to := r.opts.FromName, r.opts.ToName
+	if from == "" {
+		from = "a"
+	}
+	if to == "" {
+		to = "b"
+	}
+	return from, to
+}
+
+func (r *renderer) unified(hunks [][]lineEdit) {
+	from, to := r.names()
+	r.line(colorBold, "--- "+from)
+	r.line(colorBold, "+++ "+to)
+	for _, hunk := range hunks {
+		r.line(colorCyan, hunkHeader(hunk))
+		for _, e := range hunk {
+			switch e.op {
+			case '-':
+				r.line(colorRed, "-"+e.line)
+			case '+':
+				r.line(colorGreen, "+"+e.line)
+			default:
+				r.line("", " "+e.line)
+			}
+		}
+	}
+}
+
+// sideBySide 左右并排输出，中间一栏的 | 表示修改，< 表示删除，> 表示新增
+func (r *renderer) sideBySide(hunks [][]lineEdit) {
+	width := r.opts.Width
+	if width <= 0 {
+		width = 60
+	}
+	from, to := r.names()
+	r.row(width, from, ' ', to, colorBold, colorBold)
+	for _, hunk := range hunks {
+		r.line(colorCyan, hunkHeader(hunk))
+		for i := 0; i < len(hunk); {
+			if hunk[i].op == ' ' {
+				r.row(width, hunk[i].line, ' ', hunk[i].line, "", "")
+				i++
+				continue
+			}
+			var removed, added []string
+			for ; i < len(hunk) && hunk[i].op == '-'; i++ {
+				removed = append(removed, hunk[i].line)
+			}
+			for ; i < len(hunk) && hunk[i].op == '+'; i++ {
+				added = append(added, hunk[i].line)
+			}
+			for j := 0; j < len(removed) || j < len(added); j++ {
+				switch {
+				case j >= len(added):
+					r.row(width, removed[j], '<', "", colorRed, "")
+				case j >= len(removed):
+					r.row(width, "", '>', added[j], "", colorGreen)
+				default:
+					r.row(width, removed[j], '|', added[j], colorRed, colorGreen)
+				}
+			}
+		}
+	}
+}
+
+func (r *renderer) row(width int, left string, mark byte, right, leftColor, rightColor string) {
+	left = truncate(left, width)
+	right = truncate(right, width)
+	padding := strings.Repeat(" ", width-displayWidth(left))
+	if r.opts.Color {
+		if leftColor != "" && left != "" {
+			left = leftColor + left + colorReset
+		}
+		if rightColor != "" && right != "" {
+			right = rightColor + right + colorReset
+		}
+	}
+	s := left + padding + " " + string(mark)
+	if right != "" {
+		s += " " + right
+	}
+	r.line("", s)
+}
+
+// truncate 把 s 截断为显示宽度最多为 width 的前缀
+func truncate(s string, width int) string {
+	w := 0
+	for i, r := range s {
+		rw := runeWidth(r)
+		if w+rw > width {
+			return s[:i]
+		}
+		w += rw
+	}
+	return s
+}
+
+// displayWidth 返回 s 在终端中占用的列数
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// wideRanges 是 East Asian Width 为 W 或 F 的主要字符范围：中日韩文字、谚文、全角符号以及 emoji
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x2E80, 0x303E}, {0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE30, 0xFE4F}, {0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6}, {0x1F300, 0x1F64F}, {0x1F900, 0x1F9FF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+// runeWidth 返回 r 在终端中占用的列数：组合字符和格式字符为 0，宽字符为 2，其他为 1
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, rg := range wideRanges {
+		if r >= rg[0] && r <= rg[1] {
+			return 2
+		}
+	}
+	return 1
+}
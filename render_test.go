@@ -0,0 +1,197 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"bytes"
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"strings"
+	"testing"
+)
+
+func TestRenderUnified(t *testing.T) {
+	src := `{"name": "app", "version": 1, "spec": {"replicas": 1, "image": "nginx:1.20", "port": 80, "env": []}}`
+	dst := `{"name": "app", "version": 2, "spec": {"replicas": 1, "image": "nginx:1.21", "port": 80, "env": [], "debug": true}}`
+	tests := []struct {
+		name string
+		opts *RenderOptions
+		want string
+	}{
+		{
+			"unified",
+			&RenderOptions{Context: 1},
+			`--- a
++++ b
+@@ -2,8 +2,9 @@
+   "name": "app",
+-  "version": 1,
++  "version": 2,
+   "spec": {
+     "replicas": 1,
+-    "image": "nginx:1.20",
++    "image": "nginx:1.21",
+     "port": 80,
+-    "env": []
++    "env": [],
++    "debug": true
+   }
+`,
+		},
+		{
+			"default context merges hunks",
+			&RenderOptions{Context: 3, FromName: "old.json", ToName: "new.json"},
+			`--- old.json
++++ new.json
+@@ -1,10 +1,11 @@
+ {
+   "name": "app",
+-  "version": 1,
++  "version": 2,
+   "spec": {
+     "replicas": 1,
+-    "image": "nginx:1.20",
++    "image": "nginx:1.21",
+     "port": 80,
+-    "env": []
++    "env": [],
++    "debug": true
+   }
+ }
+`,
+		},
+		{
+			"color",
+			&RenderOptions{Context: 0, Color: true, Indent: "\t"},
+			"\x1b[1m--- a\x1b[0m\n\x1b[1m+++ b\x1b[0m\n\x1b[36m@@ -3 +3 @@\x1b[0m\n" +
+				"\x1b[31m-\t\"version\": 1,\x1b[0m\n\x1b[32m+\t\"version\": 2,\x1b[0m\n" +
+				"\x1b[36m@@ -6 +6 @@\x1b[0m\n" +
+				"\x1b[31m-\t\t\"image\": \"nginx:1.20\",\x1b[0m\n\x1b[32m+\t\t\"image\": \"nginx:1.21\",\x1b[0m\n" +
+				"\x1b[36m@@ -8 +8,2 @@\x1b[0m\n" +
+				"\x1b[31m-\t\t\"env\": []\x1b[0m\n\x1b[32m+\t\t\"env\": [],\x1b[0m\n\x1b[32m+\t\t\"debug\": true\x1b[0m\n",
+		},
+		{
+			"side by side",
+			&RenderOptions{Context: 0, SideBySide: true, Width: 20},
+			`a                      b
+@@ -3 +3 @@
+  "version": 1,      |   "version": 2,
+@@ -6 +6 @@
+    "image": "nginx: |     "image": "nginx:
+@@ -8 +8,2 @@
+    "env": []        |     "env": [],
+                     >     "debug": true
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := decode.Unmarshal([]byte(src))
+			b, _ := decode.Unmarshal([]byte(dst))
+			var buf bytes.Buffer
+			if err := RenderUnified(&buf, a, b, tt.opts); err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestRenderUnified_special(t *testing.T) {
+	a, _ := decode.Unmarshal([]byte(`{"a": [1, 2]}`))
+	b, _ := decode.Unmarshal([]byte(`{"a": [1, 2]}`))
+	var buf bytes.Buffer
+	if err := RenderUnified(&buf, a, b, nil); err != nil || buf.Len() != 0 {
+		t.Errorf("want no output, got %q, %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := RenderUnified(&buf, nil, a, nil); err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	want := "--- a\n+++ b\n@@ -0,0 +1,6 @@\n+{\n+  \"a\": [\n+    1,\n+    2\n+  ]\n+}\n"
+	if buf.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestRenderPatch(t *testing.T) {
+	src, _ := decode.Unmarshal([]byte(`{"a": 1, "b": [1, 2, 3]}`))
+	patch, _ := decode.Unmarshal([]byte(`[{"op": "remove", "path": "/b/1"}, {"op": "replace", "path": "/a", "value": 2}]`))
+	var buf bytes.Buffer
+	if err := RenderPatch(&buf, src, patch, &RenderOptions{Context: 0}); err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	want := "--- a\n+++ b\n@@ -2 +2 @@\n-  \"a\": 1,\n+  \"a\": 2,\n@@ -5 +4,0 @@\n-    2,\n"
+	if buf.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	patch, _ = decode.Unmarshal([]byte(`[{"op": "remove", "path": "/c"}]`))
+	err := RenderPatch(&buf, src, patch, nil)
+	if err == nil || !strings.Contains(err.Error(), "/c") {
+		t.Errorf("want an error, got %v", err)
+	}
+}
+
+func TestRenderUnified_wideCharacters(t *testing.T) {
+	a, _ := decode.Unmarshal([]byte(`{"名称": "测试", "说明": "一个很长的中文说明", "v": 1}`))
+	b, _ := decode.Unmarshal([]byte(`{"名称": "测试数据", "说明": "一个很长的中文说明!", "v": 2}`))
+	var buf bytes.Buffer
+	if err := RenderUnified(&buf, a, b, &RenderOptions{Context: 0, SideBySide: true, Width: 20}); err != nil {
+		t.Fatalf("got an error: %v", err)
+	}
+	want := `a                      b
+@@ -2,3 +2,3 @@
+  "名称": "测试",    |   "名称": "测试数据"
+  "说明": "一个很长  |   "说明": "一个很长
+  "v": 1             |   "v": 2
+`
+	if buf.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, buf.String())
+	}
+	// 宽字符占两列，每一行的 | 都应当在同一列
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n")[2:] {
+		if i := strings.Index(line, "|"); displayWidth(line[:i]) != 21 {
+			t.Errorf("misaligned line %q", line)
+		}
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+	}{
+		{"abc", 3},
+		{"中文", 4},
+		{"ｆｕｌｌ", 8},
+		{"한국어", 6},
+		{"é", 1},
+		{"😀", 2},
+	}
+	for _, tt := range tests {
+		if got := displayWidth(tt.s); got != tt.width {
+			t.Errorf("%q: want %d, got %d", tt.s, tt.width, got)
+		}
+	}
+	if got := truncate("ab中文", 3); got != "ab" {
+		t.Errorf("want ab, got %q", got)
+	}
+}
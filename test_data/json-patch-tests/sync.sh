@@ -0,0 +1,15 @@
+#!/bin/sh
+# 从上游同步 json-patch-tests 测试集，并把上游的 commit 记录到 UPSTREAM 文件中。
+# 用法：./sync.sh [ref]，ref 默认为 master
+set -e
+
+ref=${1:-master}
+dir=$(cd "$(dirname "$0")" && pwd)
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+git clone -q https://github.com/json-patch/json-patch-tests.git "$tmp/json-patch-tests"
+git -C "$tmp/json-patch-tests" checkout -q "$ref"
+cp "$tmp/json-patch-tests/tests.json" "$tmp/json-patch-tests/spec_tests.json" "$dir/"
+git -C "$tmp/json-patch-tests" rev-parse HEAD > "$dir/UPSTREAM"
+echo "synced json-patch-tests $(cat "$dir/UPSTREAM")"
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"math"
+	"math/big"
+)
+
+// toleranceOption 指定比较路径匹配 patterns 的数值时允许的误差，patterns 为空时对所有数值生效
+type toleranceOption struct {
+	abs, rel float64
+	all      bool
+	patterns [][]string
+}
+
+func (o toleranceOption) apply(cfg *diffConfig) {
+	if o.all || len(o.patterns) > 0 {
+		cfg.tolerances = append(cfg.tolerances, o)
+	}
+}
+
+// UseToleranceOption 比较两个数值时允许一定的误差：两者之差的绝对值不超过 abs，
+// 或者不超过 rel 乘以两者中绝对值较大的一个时认为它们相等，abs 和 rel 为 0 表示不使用对应的误差，
+// 差值按十进制精确计算，超过 float64 精度的大整数也不会因为舍入被认为相等。
+// 不传 patterns 时对所有数值生效，否则只对路径匹配某个 pattern 的数值生效，pattern 的格式与 UseIncludeOption 相同；
+// 多次设置时先设置的优先。该选项同样作用于 Equal、数组最长公共子序列的对齐以及 MergeDiff 中的 test 操作
+func UseToleranceOption(abs, rel float64, patterns ...string) DiffOption {
+	if len(patterns) == 0 {
+		return toleranceOption{abs: abs, rel: rel, all: true}
+	}
+	return toleranceOption{abs: abs, rel: rel, patterns: parsePatterns(patterns)}
+}
+
+// valueEqual 比较 path 处的两个值节点，数值使用 UseToleranceOption 设置的误差比较
+func (cfg *diffConfig) valueEqual(path decode.Pointer, a, b *decode.JsonNode) bool {
+	if a.Equal(b) {
+		return true
+	}
+	if len(cfg.tolerances) == 0 {
+		return false
+	}
+	an, ok := decode.ToNumber(a.Value)
+	if !ok {
+		return false
+	}
+	bn, ok := decode.ToNumber(b.Value)
+	if !ok {
+		return false
+	}
+	o, ok := cfg.tolerance(path)
+	if !ok {
+		return false
+	}
+	// 先精确比较，误差为 0 时不能转换为 float64，否则超过 2^53 的不同整数会被认为相等
+	if an.Equal(bn) {
+		return true
+	}
+	if o.abs <= 0 && o.rel <= 0 {
+		return false
+	}
+	ar, errA := an.Rat()
+	br, errB := bn.Rat()
+	if errA == nil && errB == nil {
+		return ratWithin(ar, br, o)
+	}
+	af, err := an.Float64()
+	if err != nil {
+		return false
+	}
+	bf, err := bn.Float64()
+	if err != nil {
+		return false
+	}
+	delta := math.Abs(af - bf)
+	return delta <= o.abs || delta <= o.rel*math.Max(math.Abs(af), math.Abs(bf))
+}
+
+// ratWithin 精确地判断 a 和 b 之差是否在误差 o 之内
+func ratWithin(a, b *big.Rat, o toleranceOption) bool {
+	delta := new(big.Rat).Sub(a, b)
+	delta.Abs(delta)
+	if o.abs > 0 {
+		limit := new(big.Rat).SetFloat64(o.abs)
+		if limit == nil || delta.Cmp(limit) <= 0 {
+			return true
+		}
+	}
+	if o.rel > 0 {
+		m := new(big.Rat).Abs(a)
+		if bAbs := new(big.Rat).Abs(b); bAbs.Cmp(m) > 0 {
+			m = bAbs
+		}
+		rel := new(big.Rat).SetFloat64(o.rel)
+		if rel == nil || delta.Cmp(m.Mul(m, rel)) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// tolerance 返回 path 处的数值使用的误差
+func (cfg *diffConfig) tolerance(path decode.Pointer) (toleranceOption, bool) {
+	tokens := path.Tokens()
+	for _, o := range cfg.tolerances {
+		if o.all {
+			return o, true
+		}
+		for _, pattern := range o.patterns {
+			if matchGlob(pattern, tokens) {
+				return o, true
+			}
+		}
+	}
+	return toleranceOption{}, false
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestUseToleranceOption(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		tar     string
+		options []DiffOption
+		want    string
+	}{
+		{
+			"no tolerance",
+			`{"a": 1.0}`,
+			`{"a": 1.0000000001}`,
+			nil,
+			`[{"op":"replace","path":"/a","value":1.0000000001}]`,
+		},
+		{
+			"absolute",
+			`{"a": 1.0, "b": 100}`,
+			`{"a": 1.0000000001, "b": 100.5}`,
+			[]DiffOption{UseToleranceOption(1e-6, 0)},
+			`[{"op":"replace","path":"/b","value":100.5}]`,
+		},
+		{
+			"relative",
+			`{"a": 1.0, "b": 100}`,
+			`{"a": 1.0000000001, "b": 100.5}`,
+			[]DiffOption{UseToleranceOption(0, 0.01)},
+			`[]`,
+		},
+		{
+			"per path",
+			`{"price": 9.99, "qty": 1.0}`,
+			`{"price": 9.990000001, "qty": 1.000000001}`,
+			[]DiffOption{UseToleranceOption(1e-6, 0, "/price")},
+			`[{"op":"replace","path":"/qty","value":1.000000001}]`,
+		},
+		{
+			"in lcs",
+			`{"readings": [1.0, 2.0, 3.0]}`,
+			`{"readings": [0.5, 1.0000001, 2.0000001, 3.0000001]}`,
+			[]DiffOption{UseToleranceOption(1e-3, 0, "/readings/*")},
+			`[{"op":"add","path":"/readings/0","value":0.5}]`,
+		},
+		{
+			"big integers with zero tolerance",
+			`{"id": 9007199254740992}`,
+			`{"id": 9007199254740993}`,
+			[]DiffOption{UseToleranceOption(0, 0)},
+			`[{"op":"replace","path":"/id","value":9007199254740993}]`,
+		},
+		{
+			"big integers within tolerance",
+			`{"id": 9007199254740992, "n": 9007199254740992}`,
+			`{"id": 9007199254740993, "n": 9007199254740995}`,
+			[]DiffOption{UseToleranceOption(1, 0)},
+			`[{"op":"replace","path":"/n","value":9007199254740995}]`,
+		},
+		{
+			"strings are not numbers",
+			`["1.0"]`,
+			`["1.00"]`,
+			[]DiffOption{UseToleranceOption(1, 1)},
+			`[{"op":"replace","path":"/0","value":"1.00"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := AsDiffs([]byte(tt.src), []byte(tt.tar), tt.options...)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if string(diffs) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, diffs)
+			}
+			a, _ := decode.Unmarshal([]byte(tt.src))
+			b, _ := decode.Unmarshal([]byte(tt.tar))
+			if got, want := Equal(a, b, tt.options...), tt.want == `[]`; got != want {
+				t.Errorf("Equal = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUseToleranceOption_mergeTest(t *testing.T) {
+	src := []byte(`{"a": {"b": 1.0, "c": [2.0]}}`)
+	diffs := []byte(`[{"op": "test", "path": "/a", "value": {"b": 1.0000001, "c": [1.9999999]}}]`)
+	_, err := MergeDiff(src, diffs)
+	if !errors.Is(err, ErrTestFailed) {
+		t.Errorf("want ErrTestFailed, got %v", err)
+	}
+	if _, err := MergeDiff(src, diffs, UseToleranceOption(1e-6, 0)); err != nil {
+		t.Errorf("got an error: %v", err)
+	}
+	if _, err := MergeDiff(src, diffs, UseToleranceOption(1e-6, 0, "/a/b")); !errors.Is(err, ErrTestFailed) {
+		t.Errorf("want ErrTestFailed, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"strconv"
+)
+
+// diffUnorderedSlice 把 source 和 patch 当作多重集合比较：先删除 patch 中没有的元素，
+// 再在末尾添加 source 中没有的元素，保留下来的元素不会移动。
+// 设置了 UseArrayKeyOption 且元素满足按 key 比较的条件时，key 相同的元素会被递归比较
+func diffUnorderedSlice(diffs *diffs, path decode.Pointer, source, patch *decode.JsonNode, cfg *diffConfig) {
+	match, keyed := cfg.matchKeyed(path, source, patch)
+	if !keyed {
+		match = cfg.matchUnordered(path, source, patch)
+	}
+	used := make([]bool, len(patch.Children))
+	kept := 0
+	for i, j := range match {
+		if j >= 0 {
+			used[j] = true
+			kept++
+			continue
+		}
+		currPath := path.Append(strconv.Itoa(kept))
+		diffs.add(newDiffNode(DiffTypeRemove, currPath.String(), source.Children[i], "", cfg.flags))
+	}
+	if keyed {
+		pos := 0
+		for i, j := range match {
+			if j >= 0 {
+				diff(diffs, path.Append(strconv.Itoa(pos)), source.Children[i], patch.Children[j], cfg)
+				pos++
+			}
+		}
+	}
+	for j, child := range patch.Children {
+		if used[j] {
+			continue
+		}
+		diffs.add(newDiffNode(DiffTypeAdd, path.Append(strconv.Itoa(kept)).String(), child, "", cfg.flags))
+		kept++
+	}
+}
+
+// matchUnordered 为 source 中的每个元素在 patch 中找一个相等且未被使用的元素，
+// 返回值的第 i 项是与 source.Children[i] 匹配的 patch 下标，没有匹配时为 -1
+func (cfg *diffConfig) matchUnordered(path decode.Pointer, source, patch *decode.JsonNode) []int {
+	match := make([]int, len(source.Children))
+	used := make([]bool, len(patch.Children))
+	for i, src := range source.Children {
+		match[i] = -1
+		elemPath := path.Append(strconv.Itoa(i))
+		for j, tar := range patch.Children {
+			if !used[j] && cfg.equal(elemPath, src, tar) {
+				used[j] = true
+				match[i] = j
+				break
+			}
+		}
+	}
+	return match
+}
+
+// matchKeyed 按 UseArrayKeyOption 指定的 key 匹配 source 和 patch 中的元素，
+// 没有对应的选项或不满足按 key 比较的条件时第二个参数返回 false
+func (cfg *diffConfig) matchKeyed(path decode.Pointer, source, patch *decode.JsonNode) ([]int, bool) {
+	key, ok := cfg.arrayKey(path)
+	if !ok {
+		return nil, false
+	}
+	srcKeys, ok := identities(source, key)
+	if !ok {
+		return nil, false
+	}
+	tarKeys, ok := identities(patch, key)
+	if !ok {
+		return nil, false
+	}
+	tarIndex := make(map[string]int, len(tarKeys))
+	for j, k := range tarKeys {
+		tarIndex[k] = j
+	}
+	match := make([]int, len(srcKeys))
+	for i, k := range srcKeys {
+		match[i] = -1
+		if j, ok := tarIndex[k]; ok {
+			match[i] = j
+		}
+	}
+	return match, true
+}
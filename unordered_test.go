@@ -0,0 +1,121 @@
+/*
+ * Copyright 2021 Junebao
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package json_diff
+
+import (
+	"github.com/520MianXiangDuiXiang520/json-diff/decode"
+	"testing"
+)
+
+func TestUseUnorderedArrayOption(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		tar     string
+		options []DiffOption
+		want    string
+	}{
+		{
+			"reorder only",
+			`{"tags": ["a", "b", "c"]}`,
+			`{"tags": ["c", "a", "b"]}`,
+			[]DiffOption{UseUnorderedArrayOption()},
+			`[]`,
+		},
+		{
+			"add and remove",
+			`["a", "b", "c"]`,
+			`["d", "c", "a"]`,
+			[]DiffOption{UseUnorderedArrayOption()},
+			`[{"op":"remove","path":"/1"},{"op":"add","path":"/2","value":"d"}]`,
+		},
+		{
+			"multiset",
+			`["a", "a", "b"]`,
+			`["b", "a", "b"]`,
+			[]DiffOption{UseUnorderedArrayOption()},
+			`[{"op":"remove","path":"/1"},{"op":"add","path":"/2","value":"b"}]`,
+		},
+		{
+			"pattern",
+			`{"tags": ["a", "b"], "list": ["a", "b"]}`,
+			`{"tags": ["b", "a"], "list": ["b", "a"]}`,
+			[]DiffOption{UseUnorderedArrayOption("/tags")},
+			`[{"op":"remove","path":"/list/0"},{"op":"add","path":"/list/1","value":"a"}]`,
+		},
+		{
+			"nested pattern",
+			`{"items": [{"labels": [1, 2]}, {"labels": [3, 4]}]}`,
+			`{"items": [{"labels": [4, 3, 5]}, {"labels": [2, 1]}]}`,
+			[]DiffOption{UseUnorderedArrayOption("/items/*/labels")},
+			`[{"op":"add","path":"/items/0","value":{"labels":[4,3,5]}},{"op":"remove","path":"/items/2"}]`,
+		},
+		{
+			"unordered elements with keys",
+			`[{"id": 1, "v": 1}, {"id": 2, "v": 2}, {"id": 3, "v": 3}]`,
+			`[{"id": 4, "v": 4}, {"id": 3, "v": 3}, {"id": 1, "v": 0}]`,
+			[]DiffOption{UseUnorderedArrayOption(), UseArrayKeyOption("/*", "id")},
+			`[{"op":"remove","path":"/1"},{"op":"replace","path":"/0/v","value":0},{"op":"add","path":"/2","value":{"id":4,"v":4}}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := AsDiffs([]byte(tt.src), []byte(tt.tar), tt.options...)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			if string(diffs) != tt.want {
+				t.Errorf("want %s, got %s", tt.want, diffs)
+			}
+			res, err := MergeDiff([]byte(tt.src), diffs)
+			if err != nil {
+				t.Fatalf("got an error: %v", err)
+			}
+			got, _ := decode.Unmarshal(res)
+			want, _ := decode.Unmarshal([]byte(tt.tar))
+			if !Equal(got, want, tt.options...) {
+				t.Errorf("want %s, got %s", tt.tar, res)
+			}
+		})
+	}
+}
+
+func TestEqual_unordered(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		options []DiffOption
+		want    bool
+	}{
+		{`[1, 2, 3]`, `[3, 2, 1]`, nil, false},
+		{`[1, 2, 3]`, `[3, 2, 1]`, []DiffOption{UseUnorderedArrayOption()}, true},
+		{`[1, 1, 2]`, `[1, 2, 2]`, []DiffOption{UseUnorderedArrayOption()}, false},
+		{`[1, 1, 2]`, `[1, 2, 1]`, []DiffOption{UseUnorderedArrayOption()}, true},
+		{`{"a": [1, 2], "b": [1, 2]}`, `{"a": [2, 1], "b": [1, 2]}`, []DiffOption{UseUnorderedArrayOption("/a")}, true},
+		{`{"a": [1, 2], "b": [1, 2]}`, `{"a": [1, 2], "b": [2, 1]}`, []DiffOption{UseUnorderedArrayOption("/a")}, false},
+		{`[[1, 2], [3, 4]]`, `[[4, 3], [2, 1]]`, []DiffOption{UseUnorderedArrayOption()}, true},
+		{`[[1, 2], [3, 4]]`, `[[4, 3], [2, 1]]`, []DiffOption{UseUnorderedArrayOption("/*")}, false},
+		{`[[1, 2], [3, 4]]`, `[[2, 1], [4, 3]]`, []DiffOption{UseUnorderedArrayOption("/*")}, true},
+	}
+	for _, tt := range tests {
+		a, _ := decode.Unmarshal([]byte(tt.a))
+		b, _ := decode.Unmarshal([]byte(tt.b))
+		if got := Equal(a, b, tt.options...); got != tt.want {
+			t.Errorf("Equal(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}